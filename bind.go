@@ -4,10 +4,15 @@ import (
 	"encoding"
 	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
 	"reflect"
 	"strconv"
+	"strings"
+	"time"
 )
 
 const (
@@ -16,8 +21,66 @@ const (
 	MIMEXML2              = "text/xml"
 	MIMEPOSTForm          = "application/x-www-form-urlencoded"
 	MIMEMultipartPOSTForm = "multipart/form-data"
+	MIMEHTML              = "text/html"
+	MIMEPlain             = "text/plain"
+	MIMECSV               = "text/csv"
+
+	// structured syntax suffixes (RFC 6839), a binding registered with a suffix
+	// matches every media type ending with it, e.g. "application/vnd.api+json".
+	MIMEJSONSuffix = "+json"
+	MIMEXMLSuffix  = "+xml"
+)
+
+var (
+	// ErrUnsupportedMediaType is returned by Bind when no binding is registered for
+	// the Content-Type of the request.
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+
+	// ErrBodyTooLarge is returned by Bind when the request body exceeds BindOptions.MaxBodySize.
+	ErrBodyTooLarge = errors.New("request body too large")
+
+	// ErrTrailingData is returned by Bind when data follows the JSON value and
+	// BindOptions.DisallowTrailingData is set.
+	ErrTrailingData = errors.New("unexpected data after JSON value")
 )
 
+// BindOptions control how Context.Bind decodes the request body. The zero value
+// accepts any JSON and does not limit the body size.
+type BindOptions struct {
+	// DisallowUnknownFields causes JSON binding to return an error when the destination
+	// is a struct and the input contains object keys which do not match any non-ignored,
+	// exported fields in the destination.
+	DisallowUnknownFields bool
+
+	// UseNumber causes JSON binding to unmarshal a number into an interface{} as a
+	// json.Number instead of as a float64.
+	UseNumber bool
+
+	// DisallowTrailingData causes JSON binding to return ErrTrailingData when anything
+	// other than white space follows the JSON value.
+	DisallowTrailingData bool
+
+	// MaxBodySize is the maximum number of bytes read from the request body, 0 means
+	// no limit. A larger body makes Bind return an *Error with Code http.StatusRequestEntityTooLarge.
+	MaxBodySize int64
+
+	// CacheBody makes Bind read the request body into memory once, so Bind and BodyBytes
+	// can be called multiple times for the same request. The cached body is bounded by
+	// MaxBodySize, or defaultMaxCachedBodySize if MaxBodySize is 0.
+	CacheBody bool
+}
+
+// defaultMaxCachedBodySize is the maximum size of a cached body when BindOptions.MaxBodySize is not set.
+const defaultMaxCachedBodySize = 32 << 20
+
+// WithBindOptions return a middleware which replaces the engine BindOptions for the
+// routes or prefix it is used with.
+func WithBindOptions(options BindOptions) HandlerFunc {
+	return func(c *Context) {
+		c.SetBindOptions(options)
+	}
+}
+
 type BindingStruct interface {
 	Validate() error
 }
@@ -30,44 +93,120 @@ type (
 	jsonBinding struct{}
 	formBinding struct{}
 	xmlBinding  struct{}
+
+	// optionsBinding is implemented by bindings that can be configured by BindOptions.
+	optionsBinding interface {
+		bindWithOptions(*http.Request, interface{}, BindOptions) error
+	}
+
+	// converterBinding is implemented by bindings that use the converters registered
+	// with Engine.RegisterConverter.
+	converterBinding interface {
+		bindWithConverters(*http.Request, interface{}, map[reflect.Type]Converter) error
+	}
 )
 
-func bindDefault(method, contentType string) Binding {
-	var binding Binding
-	if method == http.MethodGet {
-		binding = &formBinding{}
-	} else {
-		switch contentType {
-		case MIMEJSON:
-			binding = &jsonBinding{}
-		case MIMEXML, MIMEXML2:
-			binding = &xmlBinding{}
-		default: // MIMEPOSTForm, MIMEMultipartPOSTForm
-			binding = &formBinding{}
+// defaultBindings return the bindings registered on a new engine.
+func defaultBindings() map[string]Binding {
+	return map[string]Binding{
+		MIMEJSON:              &jsonBinding{},
+		MIMEJSONSuffix:        &jsonBinding{},
+		MIMEXML:               &xmlBinding{},
+		MIMEXML2:              &xmlBinding{},
+		MIMEXMLSuffix:         &xmlBinding{},
+		MIMEPOSTForm:          &formBinding{},
+		MIMEMultipartPOSTForm: &formBinding{},
+	}
+}
+
+// bindDefault select binding by request method and content type. GET request and
+// request without Content-Type always bind from form values, otherwise the media
+// type of Content-Type is looked up in bindings, first by its full name, then by
+// its structured syntax suffix.
+func bindDefault(bindings map[string]Binding, method, contentType string) (Binding, error) {
+	if method == http.MethodGet || contentType == "" {
+		return &formBinding{}, nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, &Error{
+			Err:  err,
+			Code: http.StatusBadRequest,
 		}
 	}
 
-	return binding
+	if binding, ok := bindings[mediaType]; ok {
+		return binding, nil
+	}
+
+	if index := strings.LastIndexByte(mediaType, '+'); index >= 0 {
+		if binding, ok := bindings[mediaType[index:]]; ok {
+			return binding, nil
+		}
+	}
+
+	return nil, &Error{
+		Err:  ErrUnsupportedMediaType,
+		Code: http.StatusUnsupportedMediaType,
+		Meta: mediaType,
+	}
+}
+
+func (b *jsonBinding) Bind(req *http.Request, data interface{}) error {
+	return b.bindWithOptions(req, data, BindOptions{})
+}
+
+func (*jsonBinding) bindWithOptions(req *http.Request, data interface{}, options BindOptions) error {
+	decoder := json.NewDecoder(req.Body)
+	if options.DisallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+	if options.UseNumber {
+		decoder.UseNumber()
+	}
+
+	if err := decoder.Decode(data); err != nil {
+		return err
+	}
+
+	if options.DisallowTrailingData {
+		if _, err := decoder.Token(); err != io.EOF {
+			if err != nil && isBodyTooLarge(err) {
+				return err
+			}
+			return ErrTrailingData
+		}
+	}
+	return nil
 }
 
-func (*jsonBinding) Bind(req *http.Request, data interface{}) error {
-	return json.NewDecoder(req.Body).Decode(data)
+func (b *formBinding) Bind(req *http.Request, data interface{}) error {
+	return b.bindWithConverters(req, data, nil)
 }
 
-func (*formBinding) Bind(req *http.Request, data interface{}) error {
+func (*formBinding) bindWithConverters(req *http.Request, data interface{}, converters map[reflect.Type]Converter) error {
 	if err := req.ParseForm(); err != nil {
 		return err
 	}
-	req.ParseMultipartForm(1 << 32)
+	if err := req.ParseMultipartForm(1 << 32); err != nil && err != http.ErrNotMultipart {
+		return err
+	}
 
-	return parseValues(data, req.Form)
+	return parseValues(data, req.Form, converters)
 }
 
 func (*xmlBinding) Bind(req *http.Request, data interface{}) error {
 	return xml.NewDecoder(req.Body).Decode(data)
 }
 
-func parseValues(ptr interface{}, form map[string][]string) error {
+// isBodyTooLarge reports whether err is caused by reading beyond the limit of http.MaxBytesReader.
+func isBodyTooLarge(err error) bool {
+	var maxBytesError *http.MaxBytesError
+	return errors.As(err, &maxBytesError)
+}
+
+func parseValues(ptr interface{}, form map[string][]string, converters map[reflect.Type]Converter) error {
 	typ := reflect.TypeOf(ptr).Elem()
 	val := reflect.ValueOf(ptr).Elem()
 
@@ -84,8 +223,8 @@ func parseValues(ptr interface{}, form map[string][]string) error {
 		if inputFieldName == "" {
 			inputFieldName = typeField.Name
 
-			if typeFieldKind == reflect.Struct {
-				if err := parseValues(structField.Addr().Interface(), form); err != nil {
+			if typeFieldKind == reflect.Struct && !isValueStruct(typeField.Type, converters) {
+				if err := parseValues(structField.Addr().Interface(), form, converters); err != nil {
 					return err
 				}
 				continue
@@ -93,26 +232,26 @@ func parseValues(ptr interface{}, form map[string][]string) error {
 		}
 
 		inputValue, exists := form[inputFieldName]
+		if defaultValue, ok := typeField.Tag.Lookup("default"); ok {
+			if !exists || (len(inputValue) == 1 && inputValue[0] == "") {
+				inputValue, exists = []string{defaultValue}, true
+			}
+		}
 		if !exists {
 			continue
 		}
 
 		numElems := len(inputValue)
-		if typeFieldKind == reflect.Slice && numElems > 0 {
-			sliceOf := typeField.Type.Elem().Kind()
+		if typeFieldKind == reflect.Slice && numElems > 0 && converters[typeField.Type] == nil {
 			slice := reflect.MakeSlice(typeField.Type, numElems, numElems)
 			for i := 0; i < numElems; i++ {
-				if err := setWithProperType(sliceOf, inputValue[i], slice.Index(i), false); err != nil {
+				if err := setWithProperType(inputValue[i], slice.Index(i), typeField, converters); err != nil {
 					return err
 				}
 			}
 			val.Field(i).Set(slice)
-		} else if typeFieldKind == reflect.Ptr {
-			if err := setWithProperType(typeField.Type.Elem().Kind(), inputValue[0], structField, true); err != nil {
-				return err
-			}
-		} else {
-			if err := setWithProperType(typeFieldKind, inputValue[0], structField, false); err != nil {
+		} else if numElems > 0 {
+			if err := setWithProperType(inputValue[0], structField, typeField, converters); err != nil {
 				return err
 			}
 		}
@@ -120,13 +259,51 @@ func parseValues(ptr interface{}, form map[string][]string) error {
 	return nil
 }
 
-func setWithProperType(valueKind reflect.Kind, val string, structField reflect.Value, isPtrType bool) error {
-	if isPtrType {
-		structField.Set(reflect.New(structField.Type().Elem()))
-		structField = structField.Elem()
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// Converter converts a form or query value to a value of the type it is registered for.
+type Converter func(string) (interface{}, error)
+
+// isValueStruct reports whether typ is a struct that is set from a single value, rather than
+// bound field by field.
+func isValueStruct(typ reflect.Type, converters map[reflect.Type]Converter) bool {
+	return typ == timeType || converters[typ] != nil
+}
+
+func setWithProperType(val string, structField reflect.Value, typeField reflect.StructField, converters map[reflect.Type]Converter) error {
+	if converter := converters[structField.Type()]; converter != nil {
+		v, err := converter(val)
+		if err != nil {
+			return err
+		}
+		if v == nil {
+			structField.Set(reflect.Zero(structField.Type()))
+			return nil
+		}
+		rv := reflect.ValueOf(v)
+		if !rv.Type().AssignableTo(structField.Type()) {
+			return fmt.Errorf("converter returns %v, not assignable to %v", rv.Type(), structField.Type())
+		}
+		structField.Set(rv)
+		return nil
+	}
+
+	switch structField.Type() {
+	case timeType:
+		return setTimeField(val, structField, typeField)
+	case durationType:
+		return setDurationField(val, structField)
 	}
 
-	switch valueKind {
+	switch structField.Kind() {
+	case reflect.Ptr:
+		if structField.IsNil() {
+			structField.Set(reflect.New(structField.Type().Elem()))
+		}
+		return setWithProperType(val, structField.Elem(), typeField, converters)
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		return setIntField(val, structField.Type().Bits(), structField)
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
@@ -143,6 +320,55 @@ func setWithProperType(valueKind reflect.Kind, val string, structField reflect.V
 	return nil
 }
 
+// setTimeField parses val with the layout in time_format tag, default is time.RFC3339,
+// "unix" and "unixnano" parse val as the number of seconds or nanoseconds since the epoch.
+// Layouts without time zone are parsed in UTC if time_utc tag is true, otherwise in local time.
+func setTimeField(val string, field reflect.Value, typeField reflect.StructField) error {
+	if val == "" {
+		field.Set(reflect.ValueOf(time.Time{}))
+		return nil
+	}
+
+	layout := typeField.Tag.Get("time_format")
+	switch layout {
+	case "":
+		layout = time.RFC3339
+	case "unix", "unixnano":
+		n, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return err
+		}
+		t := time.Unix(n, 0)
+		if layout == "unixnano" {
+			t = time.Unix(0, n)
+		}
+		field.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	loc := time.Local
+	if isUTC, _ := strconv.ParseBool(typeField.Tag.Get("time_utc")); isUTC {
+		loc = time.UTC
+	}
+
+	t, err := time.ParseInLocation(layout, val, loc)
+	if err == nil {
+		field.Set(reflect.ValueOf(t))
+	}
+	return err
+}
+
+func setDurationField(val string, field reflect.Value) error {
+	if val == "" {
+		val = "0"
+	}
+	d, err := time.ParseDuration(val)
+	if err == nil {
+		field.SetInt(int64(d))
+	}
+	return err
+}
+
 func tryUnmarshalValue(v reflect.Value, str string) error {
 	if v.Kind() != reflect.Ptr && v.Type().Name() != "" && v.CanAddr() {
 		v = v.Addr()
@@ -9,6 +9,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -45,8 +47,7 @@ func TestBindJSON(t *testing.T) {
 		assert.Empty(t, form.Other)
 		assert.Equal(t, "aaa", form.Payload.A)
 		assert.Equal(t, 222, form.Payload.B)
-		c.Status(statusCode)
-		c.String(serverResponse)
+		c.String(statusCode, serverResponse)
 	})
 
 	server := httptest.NewServer(router)
@@ -104,8 +105,7 @@ func TestBindXML(t *testing.T) {
 		assert.Equal(t, "cssivision", form.Name)
 		assert.Equal(t, 21, form.Age)
 		assert.Empty(t, form.Other)
-		c.Status(statusCode)
-		c.String(serverResponse)
+		c.String(statusCode, serverResponse)
 	})
 
 	server := httptest.NewServer(router)
@@ -159,8 +159,7 @@ func TestBindForm(t *testing.T) {
 			assert.Equal(t, "cssivision", *form.Name)
 			assert.Equal(t, 21, *form.Age)
 			assert.Empty(t, form.Other)
-			c.Status(statusCode)
-			c.String(serverResponse)
+			c.String(statusCode, serverResponse)
 		})
 
 		server := httptest.NewServer(router)
@@ -193,8 +192,7 @@ func TestBindForm(t *testing.T) {
 			assert.Equal(t, "cssivision", *form.Name)
 			assert.Equal(t, 21, *form.Age)
 			assert.Empty(t, form.Other)
-			c.Status(statusCode)
-			c.String(serverResponse)
+			c.String(statusCode, serverResponse)
 		})
 
 		server := httptest.NewServer(router)
@@ -242,8 +240,7 @@ func TestBindPostForm(t *testing.T) {
 		assert.Equal(t, "cssivision", form.Name)
 		assert.Equal(t, 21, form.Age)
 		assert.Empty(t, form.Other)
-		c.Status(statusCode)
-		c.String(serverResponse)
+		c.String(statusCode, serverResponse)
 	})
 
 	server := httptest.NewServer(router)
@@ -297,8 +294,7 @@ func TestBindMultiPart(t *testing.T) {
 		assert.Equal(t, "cssivision", form.Name)
 		assert.Equal(t, 21, form.Age)
 		assert.Empty(t, form.Other)
-		c.Status(statusCode)
-		c.String(serverResponse)
+		c.String(statusCode, serverResponse)
 	})
 
 	server := httptest.NewServer(router)
@@ -378,8 +374,7 @@ func TestMutliDataType(t *testing.T) {
 		assert.Equal(t, 21, form.SubInfo.SubAge)
 		assert.NotNil(t, form.Time)
 
-		c.Status(statusCode)
-		c.String(serverResponse)
+		c.String(statusCode, serverResponse)
 	})
 
 	server := httptest.NewServer(router)
@@ -422,3 +417,272 @@ func TestMutliDataType(t *testing.T) {
 	}
 	assert.Equal(t, serverResponse, string(bodyBytes))
 }
+
+func TestBindMediaType(t *testing.T) {
+	bindStatus := func(t *testing.T, router *Engine, contentType, body string) int {
+		server := httptest.NewServer(router)
+		defer server.Close()
+
+		resp, err := http.Post(server.URL, contentType, bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	newRouter := func(t *testing.T) *Engine {
+		router := New()
+		router.Post("/", func(c *Context) {
+			form := new(Info4)
+			if err := c.Bind(form); err != nil {
+				c.Status(err.(*Error).Code)
+				return
+			}
+			assert.Equal(t, "cssivision", form.Name)
+			assert.Equal(t, 21, form.Age)
+		})
+		return router
+	}
+
+	t.Run("json with parameters", func(t *testing.T) {
+		status := bindStatus(t, newRouter(t), "application/json; charset=utf-8", `{"name":"cssivision","age":21}`)
+		assert.Equal(t, http.StatusOK, status)
+	})
+
+	t.Run("json suffix", func(t *testing.T) {
+		status := bindStatus(t, newRouter(t), "application/vnd.api+json", `{"name":"cssivision","age":21}`)
+		assert.Equal(t, http.StatusOK, status)
+	})
+
+	t.Run("xml suffix", func(t *testing.T) {
+		router := New()
+		router.Post("/", func(c *Context) {
+			form := new(Info2)
+			assert.Nil(t, c.Bind(form))
+			assert.Equal(t, "cssivision", form.Name)
+			assert.Equal(t, 21, form.Age)
+		})
+		status := bindStatus(t, router, "application/atom+xml", "<person><name>cssivision</name><age>21</age></person>")
+		assert.Equal(t, http.StatusOK, status)
+	})
+
+	t.Run("unsupported media type", func(t *testing.T) {
+		status := bindStatus(t, newRouter(t), "text/csv", "name,age")
+		assert.Equal(t, http.StatusUnsupportedMediaType, status)
+	})
+
+	t.Run("invalid media type", func(t *testing.T) {
+		status := bindStatus(t, newRouter(t), "application/", "")
+		assert.Equal(t, http.StatusBadRequest, status)
+	})
+
+	t.Run("register binding", func(t *testing.T) {
+		router := newRouter(t)
+		router.RegisterBinding("text/csv", &csvBinding{})
+		status := bindStatus(t, router, "text/csv; header=absent", "cssivision,21")
+		assert.Equal(t, http.StatusOK, status)
+	})
+}
+
+type csvBinding struct{}
+
+func (*csvBinding) Bind(req *http.Request, data interface{}) error {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return err
+	}
+	fields := bytes.Split(body, []byte(","))
+	return parseValues(data, map[string][]string{
+		"name": {string(fields[0])},
+		"age":  {string(fields[1])},
+	}, nil)
+}
+
+func TestBindOptions(t *testing.T) {
+	bindStatus := func(t *testing.T, router *Engine, body string) int {
+		server := httptest.NewServer(router)
+		defer server.Close()
+
+		resp, err := http.Post(server.URL, MIMEJSON, bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	handler := func(c *Context) {
+		form := new(Info4)
+		if err := c.Bind(form); err != nil {
+			if e, ok := err.(*Error); ok {
+				c.Status(e.Code)
+			} else {
+				c.Status(http.StatusBadRequest)
+			}
+		}
+	}
+
+	t.Run("default", func(t *testing.T) {
+		router := New()
+		router.Post("/", handler)
+		assert.Equal(t, http.StatusOK, bindStatus(t, router, `{"name":"cssivision","unknown":1} garbage`))
+	})
+
+	t.Run("disallow unknown fields", func(t *testing.T) {
+		router := New()
+		router.BindOptions.DisallowUnknownFields = true
+		router.Post("/", handler)
+		assert.Equal(t, http.StatusOK, bindStatus(t, router, `{"name":"cssivision"}`))
+		assert.Equal(t, http.StatusBadRequest, bindStatus(t, router, `{"name":"cssivision","unknown":1}`))
+	})
+
+	t.Run("disallow trailing data", func(t *testing.T) {
+		router := New()
+		router.BindOptions.DisallowTrailingData = true
+		router.Post("/", handler)
+		assert.Equal(t, http.StatusOK, bindStatus(t, router, "{\"name\":\"cssivision\"}\n"))
+		assert.Equal(t, http.StatusBadRequest, bindStatus(t, router, `{"name":"cssivision"} garbage`))
+		assert.Equal(t, http.StatusBadRequest, bindStatus(t, router, `{"name":"cssivision"}}`))
+		assert.Equal(t, http.StatusBadRequest, bindStatus(t, router, `{"name":"cssivision"}{}`))
+	})
+
+	t.Run("use number", func(t *testing.T) {
+		router := New()
+		router.BindOptions.UseNumber = true
+		router.Post("/", func(c *Context) {
+			form := new(Info7)
+			assert.Nil(t, c.Bind(form))
+			assert.Equal(t, json.Number("12345678901234567890"), form.Value)
+		})
+		assert.Equal(t, http.StatusOK, bindStatus(t, router, `{"value":12345678901234567890}`))
+	})
+
+	t.Run("max body size", func(t *testing.T) {
+		router := New()
+		router.BindOptions.MaxBodySize = 32
+		router.Post("/", handler)
+		assert.Equal(t, http.StatusOK, bindStatus(t, router, `{"name":"cssivision"}`))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, bindStatus(t, router, `{"name":"cssivision","other":"too large body"}`))
+	})
+
+	t.Run("route options", func(t *testing.T) {
+		router := New()
+		router.BindOptions.MaxBodySize = 32
+		router.Post("/", WithBindOptions(BindOptions{DisallowUnknownFields: true}), handler)
+		assert.Equal(t, http.StatusOK, bindStatus(t, router, `{"name":"cssivision","other":"body larger than engine limit"}`))
+		assert.Equal(t, http.StatusBadRequest, bindStatus(t, router, `{"name":"cssivision","unknown":1}`))
+	})
+}
+
+type Info7 struct {
+	Value interface{} `json:"value"`
+}
+
+func (i *Info7) Validate() error {
+	return nil
+}
+
+type userID uint64
+
+type Info8 struct {
+	Limit    int           `json:"limit" default:"10"`
+	Sort     string        `json:"sort" default:"name"`
+	Tags     []string      `json:"tags" default:"all"`
+	Date     time.Time     `json:"date" time_format:"2006-01-02" time_utc:"1"`
+	Local    *time.Time    `json:"local" time_format:"2006-01-02 15:04"`
+	Unix     time.Time     `json:"unix" time_format:"unix"`
+	Timeout  time.Duration `json:"timeout" default:"1m"`
+	ID       userID        `json:"id"`
+	IDs      []userID      `json:"ids"`
+	Optional *userID       `json:"optional"`
+}
+
+func (i *Info8) Validate() error {
+	return nil
+}
+
+func TestBindFormTags(t *testing.T) {
+	newRouter := func() *Engine {
+		router := New()
+		router.RegisterConverter(userID(0), func(s string) (interface{}, error) {
+			id, err := strconv.ParseUint(strings.TrimPrefix(s, "u-"), 10, 64)
+			return userID(id), err
+		})
+		return router
+	}
+
+	t.Run("defaults and converters", func(t *testing.T) {
+		router := newRouter()
+		router.Get("/", func(c *Context) {
+			form := new(Info8)
+			assert.Nil(t, c.Bind(form))
+
+			assert.Equal(t, 10, form.Limit)
+			assert.Equal(t, "created", form.Sort)
+			assert.Equal(t, []string{"all"}, form.Tags)
+			assert.Equal(t, time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC), form.Date)
+			assert.Equal(t, time.Date(2024, 1, 31, 8, 30, 0, 0, time.Local), *form.Local)
+			assert.Equal(t, int64(1700000000), form.Unix.Unix())
+			assert.Equal(t, time.Minute, form.Timeout)
+			assert.Equal(t, userID(42), form.ID)
+			assert.Equal(t, []userID{1, 2}, form.IDs)
+			assert.Equal(t, userID(3), *form.Optional)
+		})
+
+		server := httptest.NewServer(router)
+		defer server.Close()
+
+		query := url.Values{}
+		query.Add("limit", "")
+		query.Add("sort", "created")
+		query.Add("date", "2024-01-31")
+		query.Add("local", "2024-01-31 08:30")
+		query.Add("unix", "1700000000")
+		query.Add("id", "u-42")
+		query.Add("ids", "u-1")
+		query.Add("ids", "2")
+		query.Add("optional", "3")
+		resp, err := http.Get(server.URL + "?" + query.Encode())
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("invalid values", func(t *testing.T) {
+		for _, query := range []string{"date=31/01/2024", "timeout=forever", "id=u-x"} {
+			router := newRouter()
+			router.Get("/", func(c *Context) {
+				assert.NotNil(t, c.Bind(new(Info8)), query)
+			})
+
+			server := httptest.NewServer(router)
+			resp, err := http.Get(server.URL + "?" + query)
+			assert.Nil(t, err)
+			resp.Body.Close()
+			server.Close()
+		}
+	})
+
+	t.Run("converters of engine", func(t *testing.T) {
+		// converters registered on another engine are not used
+		router := New()
+		router.Get("/", func(c *Context) {
+			assert.NotNil(t, c.Bind(new(Info8)))
+		})
+		router.Get("/number", func(c *Context) {
+			form := new(Info8)
+			assert.Nil(t, c.Bind(form))
+			assert.Equal(t, userID(42), form.ID)
+		})
+
+		server := httptest.NewServer(router)
+		defer server.Close()
+
+		for _, path := range []string{"/?id=u-42", "/number?id=42"} {
+			resp, err := http.Get(server.URL + path)
+			assert.Nil(t, err)
+			resp.Body.Close()
+		}
+	})
+}
@@ -0,0 +1,364 @@
+package looli
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+)
+
+// default settings of compression
+const (
+	defaultCompressMinLength = 1024
+)
+
+// contentTypes which are compressed already, compressing them again wastes CPU for nothing
+var incompressibleContentTypes = []string{
+	"image/",
+	"video/",
+	"audio/",
+	"font/woff",
+	"font/woff2",
+	"application/zip",
+	"application/gzip",
+	"application/x-gzip",
+	"application/x-bzip2",
+	"application/x-7z-compressed",
+	"application/x-rar-compressed",
+	"application/x-xz",
+	"application/zstd",
+	"application/wasm",
+	"application/pdf",
+	"application/octet-stream",
+}
+
+// compressible content types which match incompressibleContentTypes
+var compressibleContentTypes = []string{
+	"image/svg+xml",
+	"image/x-icon",
+	"image/bmp",
+}
+
+// CompressOptions configures the Compress middleware.
+type CompressOptions struct {
+	// Level is the compression level of gzip and deflate, from gzip.BestSpeed to
+	// gzip.BestCompression, default is gzip.DefaultCompression.
+	Level int
+
+	// MinLength is the minimal length of body to compress, smaller bodies are sent as
+	// they are. Default is 1024 bytes, negative value compresses every body.
+	MinLength int
+
+	// ExcludedExtensions are the file extensions of request path not compressed, such as ".png".
+	ExcludedExtensions []string
+
+	// ExcludedPaths are the request path prefixes not compressed, such as "/metrics".
+	ExcludedPaths []string
+
+	// ExcludedContentTypes are the media types or prefixes of media types not compressed in
+	// addition to the builtin list of compressed formats, such as "application/x-protobuf".
+	ExcludedContentTypes []string
+}
+
+// Compress returns a middleware which compresses the responses with gzip or deflate as the
+// client accepts in Accept-Encoding header, with default options.
+func Compress() HandlerFunc {
+	return CompressWithOptions(CompressOptions{})
+}
+
+// CompressWithOptions returns a middleware which compresses the responses with gzip or
+// deflate as the client accepts in Accept-Encoding header. Responses which are small, have
+// Content-Encoding already or whose Content-Type is a compressed format such as images are
+// sent as they are. The body is written as soon as it is decided to be compressed, Flush
+// sends the compressed data to the client so streaming and Server-Sent Events work.
+//
+//	router.Use(looli.CompressWithOptions(looli.CompressOptions{
+//		ExcludedPaths: []string{"/metrics"},
+//	}))
+func CompressWithOptions(options CompressOptions) HandlerFunc {
+	if options.Level == 0 {
+		options.Level = gzip.DefaultCompression
+	}
+	if options.Level < gzip.HuffmanOnly || options.Level > gzip.BestCompression {
+		panic(fmt.Sprintf("invalid compression level: %d", options.Level))
+	}
+	if options.MinLength == 0 {
+		options.MinLength = defaultCompressMinLength
+	}
+
+	gzipPool := &sync.Pool{New: func() interface{} {
+		w, _ := gzip.NewWriterLevel(io.Discard, options.Level)
+		return w
+	}}
+	flatePool := &sync.Pool{New: func() interface{} {
+		w, _ := flate.NewWriter(io.Discard, options.Level)
+		return w
+	}}
+
+	return func(c *Context) {
+		if options.excluded(c.Request.URL.Path) || c.Header("Upgrade") != "" {
+			c.Next()
+			return
+		}
+
+		addVaryHeader(c.ResponseWriter.Header(), "Accept-Encoding")
+		codings := parseAcceptEncoding(c.Header("Accept-Encoding"))
+		gzipQuality := acceptEncodingQuality(codings, "gzip")
+		deflateQuality := acceptEncodingQuality(codings, "deflate")
+		if c.Request.Method == http.MethodHead || (gzipQuality <= 0 && deflateQuality <= 0) {
+			c.Next()
+			return
+		}
+
+		w := &compressWriter{
+			ResponseWriter: c.ResponseWriter,
+			writer:         c.writer,
+			options:        &options,
+			encoding:       "gzip",
+			pool:           gzipPool,
+		}
+		if deflateQuality > gzipQuality {
+			w.encoding, w.pool = "deflate", flatePool
+		}
+
+		c.ResponseWriter = w
+		defer func() {
+			c.ResponseWriter = w.ResponseWriter
+			if err := recover(); err != nil {
+				// the buffered response is dropped so that it can be replaced by Recover
+				w.release()
+				w.writer.buffered = false
+				panic(err)
+			}
+		}()
+		c.Next()
+		w.close()
+	}
+}
+
+// excluded reports whether the response of urlPath is not compressed.
+func (options *CompressOptions) excluded(urlPath string) bool {
+	ext := path.Ext(urlPath)
+	for _, excluded := range options.ExcludedExtensions {
+		if ext != "" && strings.EqualFold(ext, excluded) {
+			return true
+		}
+	}
+	for _, excluded := range options.ExcludedPaths {
+		if strings.HasPrefix(urlPath, excluded) {
+			return true
+		}
+	}
+	return false
+}
+
+// compressible reports whether the body of contentType is compressed.
+func (options *CompressOptions) compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, prefix := range options.ExcludedContentTypes {
+		if strings.HasPrefix(mediaType, strings.ToLower(prefix)) {
+			return false
+		}
+	}
+	for _, compressible := range compressibleContentTypes {
+		if mediaType == compressible {
+			return true
+		}
+	}
+	for _, prefix := range incompressibleContentTypes {
+		if strings.HasPrefix(mediaType, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// encoder is implemented by gzip.Writer and flate.Writer.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+// compressWriter buffers the beginning of response until it is decided to be compressed,
+// the status code is kept in the responseWriter of Context meanwhile, so that it is
+// reported by Context.StatusCode.
+type compressWriter struct {
+	http.ResponseWriter
+
+	// writer of Context, which tracks the status code and size of response
+	writer *responseWriter
+
+	options  *CompressOptions
+	encoding string
+	pool     *sync.Pool
+
+	// status code set before the header is sent, zero if it is not set
+	status int
+
+	// buffered beginning of body
+	buf []byte
+
+	// decided is true once the header is sent, encoder is not nil if the body is compressed
+	decided bool
+	encoder encoder
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.decided || w.writer.written {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	// like responseWriter, the status code set first is sent
+	if w.status != 0 {
+		w.writer.superfluousWriteHeader(code)
+		return
+	}
+
+	// informational responses are not the final response
+	if code >= 100 && code <= 199 {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+
+	w.status = code
+	w.writer.status = code
+	w.writer.buffered = true
+	if !bodyAllowedForStatus(code) || code == http.StatusPartialContent {
+		w.decide(false)
+	}
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if !w.decided {
+		if w.writer.written {
+			// the response is sent bypassing the compressWriter
+			return w.ResponseWriter.Write(data)
+		}
+		if w.status == 0 {
+			w.WriteHeader(http.StatusOK)
+		}
+
+		w.buf = append(w.buf, data...)
+		if w.options.MinLength > 0 && len(w.buf) < w.options.MinLength {
+			return len(data), nil
+		}
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+
+	if w.encoder != nil {
+		return w.encoder.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// Flush sends the header, and the compressed data written so far to the client.
+func (w *compressWriter) Flush() {
+	if !w.decided && !w.writer.written {
+		if err := w.decide(len(w.buf) > 0); err != nil {
+			return
+		}
+	}
+	if w.encoder != nil {
+		w.encoder.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack lets the caller take over the connection, the response is not compressed.
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("the ResponseWriter doesn't support the Hijacker interface")
+	}
+	return hijacker.Hijack()
+}
+
+// Unwrap returns the underlying http.ResponseWriter, it is used by http.ResponseController.
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// decide sends the header and the buffered body, the body is compressed if compress is
+// true and the response is compressible.
+func (w *compressWriter) decide(compress bool) error {
+	w.decided = true
+	header := w.ResponseWriter.Header()
+	if header.Get("Content-Type") == "" && len(w.buf) > 0 && bodyAllowedForStatus(w.status) {
+		// the Content-Type can't be detected from the compressed body by net/http
+		header.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+
+	if compress && header.Get("Content-Encoding") == "" && header.Get("Content-Range") == "" &&
+		w.options.compressible(header.Get("Content-Type")) {
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", w.encoding)
+		// the compressed body is not byte-for-byte identical
+		if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			header.Set("ETag", "W/"+etag)
+		}
+
+		w.encoder = w.pool.Get().(encoder)
+		w.encoder.Reset(w.ResponseWriter)
+	}
+
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if w.encoder != nil {
+		_, err := w.encoder.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+// close sends the buffered response and finishes the compressed body.
+func (w *compressWriter) close() {
+	if !w.decided {
+		if w.writer.written {
+			// the connection is hijacked or the response is sent bypassing the compressWriter
+			return
+		}
+		if len(w.buf) == 0 && w.status == 0 {
+			return
+		}
+		// the body is smaller than MinLength
+		w.decide(false)
+	}
+
+	if w.encoder != nil {
+		w.encoder.Close()
+		w.release()
+	}
+}
+
+// release puts the encoder back to pool.
+func (w *compressWriter) release() {
+	if w.encoder != nil {
+		w.encoder.Reset(io.Discard)
+		w.pool.Put(w.encoder)
+		w.encoder = nil
+	}
+	w.buf = nil
+}
@@ -0,0 +1,292 @@
+package looli
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func compressRequest(t *testing.T, method, url, acceptEncoding string) (*http.Response, []byte) {
+	req, err := http.NewRequest(method, url, nil)
+	assert.Nil(t, err)
+	// set Accept-Encoding explicitly so the transport doesn't decompress the body
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	return resp, body
+}
+
+func decompress(t *testing.T, encoding string, body []byte) string {
+	var reader io.Reader
+	switch encoding {
+	case "gzip":
+		gzipReader, err := gzip.NewReader(bytes.NewReader(body))
+		assert.Nil(t, err)
+		reader = gzipReader
+	case "deflate":
+		reader = flate.NewReader(bytes.NewReader(body))
+	default:
+		return string(body)
+	}
+	data, err := ioutil.ReadAll(reader)
+	assert.Nil(t, err)
+	return string(data)
+}
+
+func TestCompress(t *testing.T) {
+	large := strings.Repeat("hello world ", 200)
+	statusCodes := make(chan int, 1)
+
+	router := New()
+	router.Use(func(c *Context) {
+		c.Next()
+		statusCodes <- c.StatusCode()
+	})
+	router.Use(CompressWithOptions(CompressOptions{
+		ExcludedPaths:        []string{"/metrics"},
+		ExcludedExtensions:   []string{".txt"},
+		ExcludedContentTypes: []string{"application/x-protobuf"},
+	}))
+	router.Get("/large", func(c *Context) {
+		c.SetHeader("ETag", `"v1"`)
+		c.Status(http.StatusCreated)
+		// the status code is tracked before the header is sent
+		assert.Equal(t, http.StatusCreated, c.StatusCode())
+		assert.True(t, c.Written())
+		c.String(http.StatusAccepted, large)
+	})
+	router.Get("/small", func(c *Context) {
+		c.String(http.StatusOK, "small")
+	})
+	router.Get("/sniff", func(c *Context) {
+		c.ResponseWriter.Write([]byte("<html>" + large))
+	})
+	router.Get("/png", func(c *Context) {
+		c.DataFromReader(http.StatusOK, -1, "image/png", strings.NewReader(large), nil)
+	})
+	router.Get("/svg", func(c *Context) {
+		c.DataFromReader(http.StatusOK, int64(len(large)), "image/svg+xml", strings.NewReader(large), nil)
+	})
+	router.Get("/protobuf", func(c *Context) {
+		c.DataFromReader(http.StatusOK, -1, "application/x-protobuf", strings.NewReader(large), nil)
+	})
+	router.Get("/encoded", func(c *Context) {
+		c.SetHeader("Content-Encoding", "br")
+		c.DataFromReader(http.StatusOK, -1, "text/plain", strings.NewReader(large), nil)
+	})
+	router.Get("/metrics", func(c *Context) {
+		c.String(http.StatusOK, large)
+	})
+	router.Get("/file.txt", func(c *Context) {
+		c.String(http.StatusOK, large)
+	})
+	router.Get("/nocontent", func(c *Context) {
+		c.Status(http.StatusNoContent)
+	})
+	router.Get("/empty", func(c *Context) {})
+	router.Head("/large", func(c *Context) {
+		c.String(http.StatusOK, large)
+	})
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	cases := []struct {
+		method         string
+		path           string
+		acceptEncoding string
+		statusCode     int
+		encoding       string
+		vary           string
+		contentType    string
+		body           string
+	}{
+		{http.MethodGet, "/large", "gzip, deflate", http.StatusCreated, "gzip", "Accept-Encoding", "text/plain; charset=utf-8", large},
+		{http.MethodGet, "/large", "deflate, gzip;q=0.5", http.StatusCreated, "deflate", "Accept-Encoding", "text/plain; charset=utf-8", large},
+		{http.MethodGet, "/large", "*", http.StatusCreated, "gzip", "Accept-Encoding", "text/plain; charset=utf-8", large},
+		{http.MethodGet, "/large", "br", http.StatusCreated, "", "Accept-Encoding", "text/plain; charset=utf-8", large},
+		{http.MethodGet, "/large", "gzip;q=0, identity", http.StatusCreated, "", "Accept-Encoding", "text/plain; charset=utf-8", large},
+		{http.MethodGet, "/small", "gzip", http.StatusOK, "", "Accept-Encoding", "text/plain; charset=utf-8", "small"},
+		{http.MethodGet, "/sniff", "gzip", http.StatusOK, "gzip", "Accept-Encoding", "text/html; charset=utf-8", "<html>" + large},
+		{http.MethodGet, "/png", "gzip", http.StatusOK, "", "Accept-Encoding", "image/png", large},
+		{http.MethodGet, "/svg", "gzip", http.StatusOK, "gzip", "Accept-Encoding", "image/svg+xml", large},
+		{http.MethodGet, "/protobuf", "gzip", http.StatusOK, "", "Accept-Encoding", "application/x-protobuf", large},
+		{http.MethodGet, "/encoded", "gzip", http.StatusOK, "br", "Accept-Encoding", "text/plain", large},
+		{http.MethodGet, "/metrics", "gzip", http.StatusOK, "", "", "text/plain; charset=utf-8", large},
+		{http.MethodGet, "/file.txt", "gzip", http.StatusOK, "", "", "text/plain; charset=utf-8", large},
+		{http.MethodGet, "/nocontent", "gzip", http.StatusNoContent, "", "Accept-Encoding", "", ""},
+		{http.MethodGet, "/empty", "gzip", http.StatusOK, "", "Accept-Encoding", "", ""},
+		{http.MethodHead, "/large", "gzip", http.StatusOK, "", "Accept-Encoding", "text/plain; charset=utf-8", ""},
+	}
+	for _, tc := range cases {
+		name := tc.method + " " + tc.path + " " + tc.acceptEncoding
+		resp, body := compressRequest(t, tc.method, server.URL+tc.path, tc.acceptEncoding)
+		assert.Equal(t, tc.statusCode, resp.StatusCode, name)
+		assert.Equal(t, tc.statusCode, <-statusCodes, name)
+		assert.Equal(t, tc.encoding, resp.Header.Get("Content-Encoding"), name)
+		assert.Equal(t, tc.vary, resp.Header.Get("Vary"), name)
+		assert.Equal(t, tc.contentType, resp.Header.Get("Content-Type"), name)
+		if tc.encoding == "gzip" || tc.encoding == "deflate" {
+			// Content-Length of the uncompressed body is removed
+			if contentLength := resp.Header.Get("Content-Length"); contentLength != "" {
+				assert.Equal(t, strconv.Itoa(len(body)), contentLength, name)
+			}
+			assert.True(t, len(body) < len(tc.body), name)
+		}
+		if tc.encoding != "br" {
+			assert.Equal(t, tc.body, decompress(t, tc.encoding, body), name)
+		}
+	}
+
+	resp, _ := compressRequest(t, http.MethodGet, server.URL+"/large", "gzip")
+	<-statusCodes
+	assert.Equal(t, `W/"v1"`, resp.Header.Get("ETag"))
+	resp, _ = compressRequest(t, http.MethodGet, server.URL+"/large", "identity")
+	<-statusCodes
+	assert.Equal(t, `"v1"`, resp.Header.Get("ETag"))
+}
+
+func TestCompressOptions(t *testing.T) {
+	assert.Panics(t, func() {
+		CompressWithOptions(CompressOptions{Level: 10})
+	})
+
+	router := New()
+	router.Use(CompressWithOptions(CompressOptions{Level: gzip.BestSpeed, MinLength: -1}))
+	router.Get("/small", func(c *Context) {
+		c.String(http.StatusOK, "small")
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, body := compressRequest(t, http.MethodGet, server.URL+"/small", "gzip")
+	assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+	assert.Equal(t, "small", decompress(t, "gzip", body))
+}
+
+func TestCompressStream(t *testing.T) {
+	next := make(chan struct{})
+	router := New()
+	router.Use(Compress())
+	router.Get("/events", func(c *Context) {
+		assert.Nil(t, c.SSEvent("message", "first"))
+		<-next
+		assert.Nil(t, c.SSEvent("message", "second"))
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/events", nil)
+	assert.Nil(t, err)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	// the first event is received before the handler returns
+	gzipReader, err := gzip.NewReader(resp.Body)
+	assert.Nil(t, err)
+	reader := bufio.NewReader(gzipReader)
+	line, err := reader.ReadString('\n')
+	assert.Nil(t, err)
+	assert.Equal(t, "event: message\n", line)
+	line, err = reader.ReadString('\n')
+	assert.Nil(t, err)
+	assert.Equal(t, "data: first\n", line)
+
+	close(next)
+	rest, err := ioutil.ReadAll(reader)
+	assert.Nil(t, err)
+	assert.Equal(t, "\nevent: message\ndata: second\n\n", string(rest))
+}
+
+func TestCompressDebugCommitted(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	errorWriter := defaultErrorWriter
+	defaultErrorWriter = buffer
+	defer func() {
+		defaultErrorWriter = errorWriter
+	}()
+
+	router := New()
+	router.Debug = true
+	router.Use(Compress())
+	router.Get("/status", func(c *Context) {
+		assert.False(t, c.Written())
+		c.String(http.StatusOK, "hello")
+		// the body is buffered, but the response is committed
+		assert.True(t, c.Written())
+		c.Status(http.StatusNotFound)
+	})
+	router.Get("/ok", func(c *Context) {
+		c.Status(http.StatusCreated)
+		c.SetHeader("X-Before", "1")
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, body := compressRequest(t, http.MethodGet, server.URL+"/status", "gzip")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "hello", string(body))
+	assert.Contains(t, buffer.String(), "status 404 is set after the response was committed with status 200, at ")
+	assert.Contains(t, buffer.String(), "compress_test.go:")
+
+	buffer.Reset()
+	resp, _ = compressRequest(t, http.MethodGet, server.URL+"/ok", "gzip")
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Contains(t, buffer.String(), "header X-Before is set after the response was committed")
+}
+
+func TestCompressRecover(t *testing.T) {
+	router := New()
+	router.Use(RecoverWithWriter(ioutil.Discard), Compress())
+	router.Get("/panic", func(c *Context) {
+		c.String(http.StatusOK, "partial")
+		panic("panic in handler")
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, body := compressRequest(t, http.MethodGet, server.URL+"/panic", "gzip")
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("Content-Encoding"))
+	assert.Empty(t, body)
+}
+
+func TestCompressWebSocket(t *testing.T) {
+	router := New()
+	router.Use(Compress())
+	router.Get("/ws", func(c *Context) {
+		ws, err := c.Upgrade()
+		if !assert.Nil(t, err) {
+			return
+		}
+		defer ws.Close(CloseNormalClosure, "")
+		ws.WriteMessage(TextMessage, []byte(strings.Repeat("a", 2048)))
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	client := dialWebSocket(t, server.URL, "/ws", http.Header{"Accept-Encoding": {"gzip"}})
+	defer client.conn.Close()
+	assert.Equal(t, http.StatusSwitchingProtocols, client.resp.StatusCode)
+	assert.Empty(t, client.resp.Header.Get("Content-Encoding"))
+	b0, payload := client.readFrame(t)
+	assert.Equal(t, byte(finBit|TextMessage), b0)
+	assert.Equal(t, strings.Repeat("a", 2048), string(payload))
+}
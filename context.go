@@ -1,7 +1,9 @@
 package looli
 
 import (
+	"bytes"
 	"html/template"
+	"io"
 	"math"
 	"net"
 	"net/http"
@@ -30,6 +32,9 @@ type Context struct {
 	// Short for Request.URL.Path
 	Path string
 
+	// route pattern matched by the request
+	pattern string
+
 	// Short for Request.Method
 	Method string
 
@@ -37,8 +42,27 @@ type Context struct {
 	template *template.Template
 	engine   *Engine
 
-	// statusCode that write to response
-	statusCode int
+	// htmlRenderer takes precedence over template to render HTML
+	htmlRenderer HTMLRenderer
+
+	// writer tracks status code and size of the response
+	writer *responseWriter
+
+	// bindOptions used by Bind, default is engine.BindOptions
+	bindOptions BindOptions
+
+	// bodyLimited is true once Request.Body has been wrapped by http.MaxBytesReader
+	bodyLimited bool
+
+	// body is the cached request body, it is valid when bodyCached is true, bodyErr is the
+	// error of reading the body, it is returned by later BodyBytes calls
+	body       []byte
+	bodyErr    error
+	bodyCached bool
+
+	// envelope meta set by SetEnvelopeMeta, noEnvelope is set by NoEnvelope middleware
+	envelopeMeta map[string]interface{}
+	noEnvelope   bool
 
 	// Error when processing request
 	Err *Error
@@ -49,15 +73,18 @@ type JSON map[string]interface{}
 const abortIndex int8 = math.MaxInt8 / 2
 
 func NewContext(p *RouterPrefix, rw http.ResponseWriter, req *http.Request) *Context {
+	writer := newResponseWriter(rw, p.engine.Debug)
 	return &Context{
-		ResponseWriter: rw,
+		ResponseWriter: writer,
 		Request:        req,
 		current:        -1,
 		Path:           req.URL.Path,
 		Method:         req.Method,
 		template:       p.engine.Template,
+		htmlRenderer:   p.engine.HTMLRenderer,
 		engine:         p.engine,
-		statusCode:     defaultStatusCode,
+		writer:         writer,
+		bindOptions:    p.engine.BindOptions,
 	}
 }
 
@@ -82,7 +109,6 @@ func (c *Context) Abort() {
 // stop the current handler. if you want to stop current handler you should return, after call abort, call
 // Abort to ensure the remaining handlers for this request are not called.
 func (c *Context) AbortWithStatus(code int) {
-	c.statusCode = code
 	c.Status(code)
 	c.Abort()
 }
@@ -92,6 +118,12 @@ func (c *Context) IsAborted() bool {
 	return c.current >= abortIndex
 }
 
+// Pattern returns the route pattern matched by the request, such as "/users/:id", it is
+// empty if no route matches.
+func (c *Context) Pattern() string {
+	return c.pattern
+}
+
 // Param return the parameters by name in the request path
 func (c *Context) Param(name string) string {
 	return c.Params[name]
@@ -181,32 +213,120 @@ func (c *Context) ClientIP() string {
 	return ""
 }
 
+// SetBindOptions replaces the options used by Bind for the rest of the request.
+func (c *Context) SetBindOptions(options BindOptions) {
+	c.bindOptions = options
+}
+
 // Bind checks the Content-Type to select a binding engine automatically,
 // Depending the "Content-Type" header different bindings are used:
-// 		"application/json" --> JSON
-// 		"application/xml"  --> XML
-// otherwise --> returns an error
-// It parses the request's body as JSON if Content-Type == "application/json" using JSON or XML as a JSON input.
-// It decodes the json payload into the struct specified as a pointer.
-// Like ParseBody() but this method also writes a 400 error if the json is not valid.
+// 		"application/json", "*/*+json" --> JSON
+// 		"application/xml", "text/xml", "*/*+xml"  --> XML
+// 		"application/x-www-form-urlencoded", "multipart/form-data" --> Form
+// other media types can be registered with Engine.RegisterBinding, GET request and request
+// without Content-Type are bound from form values. If no binding matches, an *Error with
+// Code http.StatusUnsupportedMediaType is returned.
+// It decodes the payload into the struct specified as a pointer.
 func (c *Context) Bind(data BindingStruct) error {
-	binding := bindDefault(c.Request.Method, c.ContentType())
-	if err := binding.Bind(c.Request, data); err != nil {
+	binding, err := bindDefault(c.engine.bindings, c.Request.Method, c.ContentType())
+	if err != nil {
+		return err
+	}
+
+	if c.bindOptions.CacheBody || c.bodyCached {
+		// rewind Request.Body to the cached body, so it can be bound again
+		if _, err := c.BodyBytes(); err != nil {
+			return err
+		}
+	} else if c.bindOptions.MaxBodySize > 0 && !c.bodyLimited && c.Request.Body != nil {
+		c.Request.Body = http.MaxBytesReader(c.ResponseWriter, c.Request.Body, c.bindOptions.MaxBodySize)
+		c.bodyLimited = true
+	}
+
+	if b, ok := binding.(optionsBinding); ok {
+		err = b.bindWithOptions(c.Request, data, c.bindOptions)
+	} else if b, ok := binding.(converterBinding); ok {
+		err = b.bindWithConverters(c.Request, data, c.engine.converters)
+	} else {
+		err = binding.Bind(c.Request, data)
+	}
+	if err != nil {
+		if isBodyTooLarge(err) {
+			return &Error{
+				Err:  ErrBodyTooLarge,
+				Code: http.StatusRequestEntityTooLarge,
+			}
+		}
 		return err
 	}
 	return data.Validate()
 }
 
+// BodyBytes reads the request body into memory and returns it. The body is cached, after
+// BodyBytes returns Request.Body reads the cached body from the beginning, so middleware
+// can inspect the body without stealing it from handlers, and later Bind calls use the
+// cached body as well. The body size is bounded by BindOptions.MaxBodySize, or 32MB if it
+// is not set, a larger body returns an *Error with Code http.StatusRequestEntityTooLarge.
+func (c *Context) BodyBytes() ([]byte, error) {
+	if !c.bodyCached {
+		c.bodyCached = true
+		if c.Request.Body != nil {
+			limit := c.bindOptions.MaxBodySize
+			if limit <= 0 {
+				limit = defaultMaxCachedBodySize
+			}
+
+			// the body is consumed even if it can't be read, the error is kept for later calls
+			body, err := io.ReadAll(io.LimitReader(c.Request.Body, limit+1))
+			if err != nil && !isBodyTooLarge(err) {
+				c.bodyErr = err
+			} else if err != nil || int64(len(body)) > limit {
+				c.bodyErr = &Error{
+					Err:  ErrBodyTooLarge,
+					Code: http.StatusRequestEntityTooLarge,
+				}
+			} else {
+				c.body = body
+			}
+		}
+	}
+
+	if c.bodyErr != nil {
+		return nil, c.bodyErr
+	}
+	if c.Request.Body != nil {
+		c.Request.Body = io.NopCloser(bytes.NewReader(c.body))
+	}
+	return c.body, nil
+}
+
 // WriteHeader sends an HTTP response header with status code.
 // If WriteHeader is not called explicitly, the first call to Write
 // will trigger an implicit WriteHeader(http.StatusOK).
 // Thus explicit calls to WriteHeader are mainly used to
-// send error codes.
+// send error codes. Status code set after the response was committed is
+// dropped, in debug mode it is reported with the caller's location.
 func (c *Context) Status(code int) {
-	c.statusCode = code
 	c.ResponseWriter.WriteHeader(code)
 }
 
+// StatusCode returns the status code of the response, default is http.StatusOK.
+func (c *Context) StatusCode() int {
+	return c.writer.status
+}
+
+// Written returns true if the status code of the response has been sent, or recorded by a
+// middleware which buffers the response such as Compress.
+func (c *Context) Written() bool {
+	return c.writer.written || c.writer.buffered
+}
+
+// Size returns the number of bytes written to the response body, it is the compressed size
+// if the response is compressed by the Compress middleware.
+func (c *Context) Size() int {
+	return c.writer.size
+}
+
 // Redirect replies to the request with a redirect to url, which may be a path relative to the request path.
 func (c *Context) Redirect(location string) {
 	http.Redirect(c.ResponseWriter, c.Request, location, http.StatusFound)
@@ -234,6 +354,7 @@ func (c *Context) Header(key string) string {
 // Set sets the header entries associated with key to the single element value.
 // It replaces any existing values associated with key.
 func (c *Context) SetHeader(key, value string) {
+	c.checkCommitted("header " + key)
 	if value == "" {
 		c.ResponseWriter.Header().Del(key)
 	} else {
@@ -241,6 +362,13 @@ func (c *Context) SetHeader(key, value string) {
 	}
 }
 
+// checkCommitted reports in debug mode the modification of what after the response was committed.
+func (c *Context) checkCommitted(what string) {
+	if c.writer.debug && c.Written() {
+		debugPrintf("%s is set after the response was committed, at %s", what, callerLocation())
+	}
+}
+
 // Cookie get cookie from request header by name, if err != nil, return "", err
 func (c *Context) Cookie(name string) (string, error) {
 	cookie, err := c.Request.Cookie(name)
@@ -254,6 +382,7 @@ func (c *Context) Cookie(name string) (string, error) {
 
 // SetCookie use http.SetCookie to set set-cookie header
 func (c *Context) SetCookie(cookie *http.Cookie) {
+	c.checkCommitted("cookie " + cookie.Name)
 	http.SetCookie(c.ResponseWriter, cookie)
 }
 
@@ -279,48 +408,95 @@ func (c *Context) Error(err error) {
 	c.Err = parsedError
 }
 
-// String write format string to response
-func (c *Context) String(format string, values ...interface{}) {
-	if err := renderString(c.ResponseWriter, format, values...); err != nil {
-		panic(err)
-	}
+// String write status code and format string to response
+func (c *Context) String(code int, format string, values ...interface{}) {
+	c.Render(code, StringRender{Format: format, Data: values})
 }
 
-// JSON write obj to response
-func (c *Context) JSON(data interface{}) {
-	if err := renderJSON(c.ResponseWriter, data); err != nil {
-		panic(err)
-	}
+// JSON write status code and obj to response
+func (c *Context) JSON(code int, data interface{}) {
+	c.Render(code, JSONRender{Data: data})
 }
 
-// SetResult set response code and msg
-func (c *Context) SetResult(code int, msg string) {
-	data := map[string]interface{}{
-		"code": code,
-		"msg":  msg,
-	}
+// SetResult write status code and the result code and msg in the envelope
+func (c *Context) SetResult(status, code int, msg string) {
+	c.renderEnvelope(status, &Envelope{
+		Code: code,
+		Msg:  msg,
+	})
+}
 
-	if err := renderJSON(c.ResponseWriter, data); err != nil {
-		panic(err)
+// SetBody write status code and data in the envelope, data is written as it is
+// for the routes using NoEnvelope middleware.
+func (c *Context) SetBody(status int, data interface{}) {
+	if c.noEnvelope {
+		c.JSON(status, data)
+		return
 	}
+
+	c.renderEnvelope(status, &Envelope{
+		Code:    0,
+		Msg:     "ok",
+		Data:    data,
+		HasData: true,
+	})
 }
 
-// SetBody return json body
-func (c *Context) SetBody(data interface{}) {
-	rsp := map[string]interface{}{
-		"code": 0,
-		"msg":  "ok",
-		"data": data,
-	}
+// Render writes status code and the response body rendered by r. The body is omitted for
+// status codes which do not permit a body, such as 204 and 304.
+func (c *Context) Render(code int, r Render) {
+	r.WriteContentType(c.ResponseWriter)
+	c.Status(code)
 
-	if err := renderJSON(c.ResponseWriter, rsp); err != nil {
+	if !bodyAllowedForStatus(code) {
+		return
+	}
+	if err := r.Render(c.ResponseWriter); err != nil {
 		panic(err)
 	}
 }
 
-// HTML rendder html resp
-func (c *Context) HTML(name string, data interface{}) {
-	if err := renderHTML(c.ResponseWriter, c.template, name, data); err != nil {
-		panic(err)
+// XML write status code and obj to response as XML
+func (c *Context) XML(code int, data interface{}) {
+	c.Render(code, XMLRender{Data: data})
+}
+
+// IndentedJSON write status code and obj to response as indented JSON
+func (c *Context) IndentedJSON(code int, data interface{}) {
+	c.Render(code, IndentedJSONRender{Data: data})
+}
+
+// JSONP write status code and obj to response as JSONP, the callback is read from
+// query "callback", if it is empty obj is written as JSON, if it is invalid 400 is responded.
+func (c *Context) JSONP(code int, data interface{}) {
+	callback := c.Query("callback")
+	if callback != "" && !callbackPattern.MatchString(callback) {
+		c.String(http.StatusBadRequest, ErrInvalidCallback.Error())
+		return
 	}
+
+	c.Render(code, JSONPRender{
+		Callback: callback,
+		Data:     data,
+	})
+}
+
+// SecureJSON write status code and obj to response as JSON prefixed with Engine.SecureJSONPrefix
+func (c *Context) SecureJSON(code int, data interface{}) {
+	c.Render(code, SecureJSONRender{
+		Prefix: c.engine.SecureJSONPrefix,
+		Data:   data,
+	})
+}
+
+// ASCIIJSON write status code and obj to response as JSON with non-ASCII characters escaped
+func (c *Context) ASCIIJSON(code int, data interface{}) {
+	c.Render(code, ASCIIJSONRender{Data: data})
+}
+
+// HTML write status code and the template name rendered with data, the error page of
+// templates in debug mode is written with status 500.
+func (c *Context) HTML(code int, name string, data interface{}) {
+	r, code := c.htmlRender(code, name, data)
+	c.Render(code, r)
 }
@@ -24,8 +24,7 @@ func TestQuery(t *testing.T) {
 		assert.Equal(t, c.Query("age"), "23")
 		assert.Equal(t, c.Query("bar"), "イモト")
 		assert.Empty(t, c.Query("other"))
-		c.Status(statusCode)
-		c.String(serverResponse)
+		c.String(statusCode, serverResponse)
 	})
 
 	server := httptest.NewServer(router)
@@ -53,8 +52,7 @@ func TestDefaultQuery(t *testing.T) {
 		assert.Equal(t, c.DefaultQuery("name", "biz"), "cssivision")
 		assert.Equal(t, c.DefaultQuery("age", "24"), "23")
 		assert.Equal(t, c.DefaultQuery("other", "other value"), "other value")
-		c.Status(statusCode)
-		c.String(serverResponse)
+		c.String(statusCode, serverResponse)
 	})
 
 	server := httptest.NewServer(router)
@@ -82,8 +80,7 @@ func TestPostForm(t *testing.T) {
 		assert.Equal(t, c.PostForm("page"), "11")
 		assert.Empty(t, c.PostForm("both"))
 		assert.Empty(t, c.PostForm("other"))
-		c.Status(statusCode)
-		c.String(serverResponse)
+		c.String(statusCode, serverResponse)
 	})
 
 	server := httptest.NewServer(router)
@@ -113,8 +110,7 @@ func TestDefaultPostForm(t *testing.T) {
 		assert.Equal(t, c.DefaultPostForm("page", "12"), "11")
 		assert.Equal(t, c.DefaultPostForm("both", "other"), "other")
 		assert.Empty(t, c.DefaultPostForm("other", ""))
-		c.Status(statusCode)
-		c.String(serverResponse)
+		c.String(statusCode, serverResponse)
 	})
 
 	server := httptest.NewServer(router)
@@ -154,8 +150,7 @@ func TestPostFormMultipart(t *testing.T) {
 		assert.Equal(t, c.PostForm("foo"), "bar")
 		assert.Equal(t, c.PostForm("array"), "first")
 		assert.Equal(t, c.PostForm("id"), "12")
-		c.Status(statusCode)
-		c.String(serverResponse)
+		c.String(statusCode, serverResponse)
 	})
 
 	server := httptest.NewServer(router)
@@ -192,8 +187,7 @@ func TestStatus(t *testing.T) {
 	serverResponse := "server response"
 	router := New()
 	router.Get("/", func(c *Context) {
-		c.Status(statusCode)
-		c.String(serverResponse)
+		c.String(statusCode, serverResponse)
 	})
 
 	server := httptest.NewServer(router)
@@ -218,8 +212,7 @@ func TestRedirect(t *testing.T) {
 	serverResponse := "server response"
 	router := New()
 	router.Get("/redirect", func(c *Context) {
-		c.Status(statusCode)
-		c.String(serverResponse)
+		c.String(statusCode, serverResponse)
 	})
 	router.Get("/", func(c *Context) {
 		c.Redirect("/redirect")
@@ -273,8 +266,7 @@ func TestHeader(t *testing.T) {
 	router := New()
 	router.Get("/a/b", func(c *Context) {
 		assert.Equal(t, c.Header("fake-header"), "fake")
-		c.Status(statusCode)
-		c.String(serverResponse)
+		c.String(statusCode, serverResponse)
 	})
 
 	server := httptest.NewServer(router)
@@ -306,8 +298,7 @@ func TestSetHeader(t *testing.T) {
 		router := New()
 		router.Get("/a/b", func(c *Context) {
 			c.SetHeader("fake-header", "fake")
-			c.Status(statusCode)
-			c.String(serverResponse)
+			c.String(statusCode, serverResponse)
 		})
 
 		server := httptest.NewServer(router)
@@ -330,8 +321,7 @@ func TestSetHeader(t *testing.T) {
 		router := New()
 		router.Get("/a/b", func(c *Context) {
 			c.SetHeader("fake-header", "")
-			c.Status(statusCode)
-			c.String(serverResponse)
+			c.String(statusCode, serverResponse)
 		})
 
 		server := httptest.NewServer(router)
@@ -360,8 +350,7 @@ func TestCookie(t *testing.T) {
 		val, err = c.Cookie("fake-cookie-not-exist")
 		assert.NotNil(t, err)
 		assert.Empty(t, val)
-		c.Status(statusCode)
-		c.String(serverResponse)
+		c.String(statusCode, serverResponse)
 	})
 
 	server := httptest.NewServer(router)
@@ -399,8 +388,7 @@ func TestSetCookie(t *testing.T) {
 			Name:  "fake-cookie",
 			Value: "fake",
 		})
-		c.Status(statusCode)
-		c.String(serverResponse)
+		c.String(statusCode, serverResponse)
 	})
 
 	server := httptest.NewServer(router)
@@ -434,8 +422,7 @@ func TestAbort(t *testing.T) {
 		c.Abort()
 		assert.True(t, c.IsAborted())
 		assert.Equal(t, c.current, abortIndex)
-		c.Status(statusCode)
-		c.String(serverResponse)
+		c.String(statusCode, serverResponse)
 	}
 
 	middleware3 := func(c *Context) {
@@ -479,7 +466,7 @@ func TestAbortWithStatus(t *testing.T) {
 		assert.Equal(t, c.current, abortIndex)
 	}
 	middleware2 := func(c *Context) {
-		c.String(serverResponse)
+		c.String(http.StatusOK, serverResponse)
 	}
 
 	router := New()
@@ -516,8 +503,7 @@ func TestError(t *testing.T) {
 			assert.Equal(t, c.Err.Code, 0)
 			assert.Equal(t, c.Err.Meta, nil)
 
-			c.Status(statusCode)
-			c.String(serverResponse)
+			c.String(statusCode, serverResponse)
 		})
 
 		server := httptest.NewServer(router)
@@ -549,8 +535,7 @@ func TestError(t *testing.T) {
 			assert.Equal(t, c.Err.Code, 501)
 			assert.Equal(t, c.Err.Meta, "cssivision")
 
-			c.Status(statusCode)
-			c.String(serverResponse)
+			c.String(statusCode, serverResponse)
 		})
 
 		server := httptest.NewServer(router)
@@ -574,14 +559,12 @@ func TestParam(t *testing.T) {
 	router.Get("/a/:name", func(c *Context) {
 		assert.Equal(t, "cssivision", c.Param("name"))
 		assert.Empty(t, c.Param("other"))
-		c.Status(statusCode)
-		c.String(serverResponse)
+		c.String(statusCode, serverResponse)
 	})
 
 	router.Get("/b/*filepath", func(c *Context) {
 		assert.Equal(t, "c/cssivision", c.Param("filepath"))
-		c.Status(statusCode)
-		c.String(serverResponse)
+		c.String(statusCode, serverResponse)
 	})
 
 	server := httptest.NewServer(router)
@@ -623,8 +606,7 @@ func TestClientIP(t *testing.T) {
 
 		router.Get("/a", func(c *Context) {
 			assert.Equal(t, realIP, c.ClientIP())
-			c.Status(statusCode)
-			c.String(serverResponse)
+			c.String(statusCode, serverResponse)
 		})
 
 		server := httptest.NewServer(router)
@@ -654,8 +636,7 @@ func TestClientIP(t *testing.T) {
 		router.Get("/a", func(c *Context) {
 			assert.Equal(t, "looli.xyz", c.ClientIP())
 			assert.Empty(t, c.Header("X-Real-Ip"))
-			c.Status(statusCode)
-			c.String(serverResponse)
+			c.String(statusCode, serverResponse)
 		})
 
 		server := httptest.NewServer(router)
@@ -682,8 +663,7 @@ func TestContentType(t *testing.T) {
 	router := New()
 	router.Post("/a/b", func(c *Context) {
 		assert.Equal(t, "text/plain", c.ContentType())
-		c.Status(statusCode)
-		c.String(serverResponse)
+		c.String(statusCode, serverResponse)
 	})
 
 	server := httptest.NewServer(router)
@@ -714,8 +694,7 @@ func TestString(t *testing.T) {
 	serverResponse := "server response"
 	router := New()
 	router.Get("/a/b", func(c *Context) {
-		c.Status(statusCode)
-		c.String(serverResponse)
+		c.String(statusCode, serverResponse)
 	})
 
 	server := httptest.NewServer(router)
@@ -744,8 +723,7 @@ func TestJSON(t *testing.T) {
 	statusCode := 404
 	router := New()
 	router.Get("/a/b", func(c *Context) {
-		c.Status(statusCode)
-		c.JSON(JSON{
+		c.JSON(statusCode, JSON{
 			"name": "cssivision",
 			"age":  21,
 		})
@@ -774,8 +752,7 @@ func TestHTML(t *testing.T) {
 		router := New()
 		router.LoadHTMLGlob("test/templates/*")
 		router.Get("/index.html", func(c *Context) {
-			c.Status(statusCode)
-			c.HTML("index.tmpl", JSON{
+			c.HTML(statusCode, "index.tmpl", JSON{
 				"title": "Posts",
 			})
 		})
@@ -800,7 +777,7 @@ func TestHTML(t *testing.T) {
 		router.Get("/index.html", func(c *Context) {
 			c.Status(statusCode)
 			assert.Panics(t, func() {
-				c.HTML("index.tmp", JSON{
+				c.HTML(statusCode, "index.tmp", JSON{
 					"title": "Posts",
 				})
 			})
@@ -819,3 +796,103 @@ func TestHTML(t *testing.T) {
 		assert.False(t, strings.Contains(string(bodyBytes), "Posts"))
 	})
 }
+
+func TestBodyBytes(t *testing.T) {
+	t.Run("middleware and bind", func(t *testing.T) {
+		body := `{"name":"cssivision","age":21}`
+		router := New()
+		router.Use(func(c *Context) {
+			bodyBytes, err := c.BodyBytes()
+			assert.Nil(t, err)
+			assert.Equal(t, body, string(bodyBytes))
+		})
+		router.Post("/a", func(c *Context) {
+			form := new(Info4)
+			assert.Nil(t, c.Bind(form))
+			assert.Equal(t, "cssivision", form.Name)
+
+			// bind again from the cached body
+			form = new(Info4)
+			assert.Nil(t, c.Bind(form))
+			assert.Equal(t, 21, form.Age)
+
+			_, err := c.BodyBytes()
+			assert.Nil(t, err)
+			bodyBytes, err := ioutil.ReadAll(c.Request.Body)
+			assert.Nil(t, err)
+			assert.Equal(t, body, string(bodyBytes))
+		})
+
+		server := httptest.NewServer(router)
+		defer server.Close()
+
+		resp, err := http.Post(server.URL+"/a", MIMEJSON, strings.NewReader(body))
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("cache body option", func(t *testing.T) {
+		router := New()
+		router.BindOptions.CacheBody = true
+		router.Post("/a", func(c *Context) {
+			for i := 0; i < 2; i++ {
+				form := new(Info4)
+				assert.Nil(t, c.Bind(form))
+				assert.Equal(t, "cssivision", form.Name)
+			}
+		})
+
+		server := httptest.NewServer(router)
+		defer server.Close()
+
+		resp, err := http.Post(server.URL+"/a", MIMEJSON, strings.NewReader(`{"name":"cssivision"}`))
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("body too large", func(t *testing.T) {
+		router := New()
+		router.BindOptions.MaxBodySize = 8
+		router.Post("/a", func(c *Context) {
+			_, err := c.BodyBytes()
+			assert.Equal(t, http.StatusRequestEntityTooLarge, err.(*Error).Code)
+			c.Status(err.(*Error).Code)
+		})
+
+		server := httptest.NewServer(router)
+		defer server.Close()
+
+		resp, err := http.Post(server.URL+"/a", MIMEJSON, strings.NewReader(`{"name":"cssivision"}`))
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+	})
+
+	t.Run("body too large twice", func(t *testing.T) {
+		router := New()
+		router.BindOptions.MaxBodySize = 8
+		router.Post("/a", func(c *Context) {
+			body, err := c.BodyBytes()
+			assert.Nil(t, body)
+			assert.Equal(t, http.StatusRequestEntityTooLarge, err.(*Error).Code)
+
+			// the body was consumed by the first call, the same error is returned
+			body, err2 := c.BodyBytes()
+			assert.Nil(t, body)
+			assert.Equal(t, err, err2)
+
+			assert.Equal(t, err, c.Bind(new(Info2)))
+			c.Status(err.(*Error).Code)
+		})
+
+		server := httptest.NewServer(router)
+		defer server.Close()
+
+		resp, err := http.Post(server.URL+"/a", MIMEJSON, strings.NewReader(`{"name":"cssivision"}`))
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+	})
+}
@@ -1,7 +1,6 @@
 package cors
 
 import (
-	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
@@ -92,8 +91,8 @@ func New(option Options) looli.HandlerFunc {
 		}
 
 		if !option.AllowOriginsFunc(origin) {
-			c.AbortWithStatus(http.StatusForbidden)
-			c.String(fmt.Sprintf("Origin: %v is not allowed", origin))
+			c.Abort()
+			c.String(http.StatusForbidden, "Origin: %v is not allowed", origin)
 			return
 		}
 
@@ -110,8 +109,8 @@ func New(option Options) looli.HandlerFunc {
 
 			// invalid preflighted request, missing Access-Control-Request-Method header
 			if requestMethod == "" {
-				c.AbortWithStatus(http.StatusForbidden)
-				c.String("invalid preflighted request, missing Access-Control-Request-Method header")
+				c.Abort()
+				c.String(http.StatusForbidden, "invalid preflighted request, missing Access-Control-Request-Method header")
 				return
 			}
 
@@ -20,8 +20,7 @@ func TestEmptyHost(t *testing.T) {
 	router.Use(New(Options{}))
 
 	router.Get("/a", func(c *looli.Context) {
-		c.Status(statusCode)
-		c.String(serverResponse)
+		c.String(statusCode, serverResponse)
 	})
 
 	server := httptest.NewServer(router)
@@ -57,8 +56,7 @@ func TestWithoutOptions(t *testing.T) {
 	router.Use(New(Options{}))
 
 	router.Get("/a", func(c *looli.Context) {
-		c.Status(statusCode)
-		c.String(serverResponse)
+		c.String(statusCode, serverResponse)
 	})
 
 	server := httptest.NewServer(router)
@@ -96,8 +94,7 @@ func TestAllOrigins(t *testing.T) {
 	}))
 
 	router.Get("/a", func(c *looli.Context) {
-		c.Status(statusCode)
-		c.String(serverResponse)
+		c.String(statusCode, serverResponse)
 	})
 
 	server := httptest.NewServer(router)
@@ -136,8 +133,7 @@ func TestAllowedOrigins(t *testing.T) {
 	}))
 
 	router.Get("/a", func(c *looli.Context) {
-		c.Status(statusCode)
-		c.String(serverResponse)
+		c.String(statusCode, serverResponse)
 	})
 
 	server := httptest.NewServer(router)
@@ -200,8 +196,7 @@ func TestAllowOriginsFunc(t *testing.T) {
 	}))
 
 	router.Get("/a", func(c *looli.Context) {
-		c.Status(statusCode)
-		c.String(serverResponse)
+		c.String(statusCode, serverResponse)
 	})
 
 	server := httptest.NewServer(router)
@@ -263,8 +258,7 @@ func TestAllowMethods(t *testing.T) {
 	}))
 
 	router.Get("/a", func(c *looli.Context) {
-		c.Status(statusCode)
-		c.String(serverResponse)
+		c.String(statusCode, serverResponse)
 	})
 
 	server := httptest.NewServer(router)
@@ -328,8 +322,7 @@ func TestAllowCredentials(t *testing.T) {
 		}))
 
 		router.Get("/a", func(c *looli.Context) {
-			c.Status(statusCode)
-			c.String(serverResponse)
+			c.String(statusCode, serverResponse)
 		})
 
 		server := httptest.NewServer(router)
@@ -367,8 +360,7 @@ func TestAllowCredentials(t *testing.T) {
 		}))
 
 		router.Get("/a", func(c *looli.Context) {
-			c.Status(statusCode)
-			c.String(serverResponse)
+			c.String(statusCode, serverResponse)
 		})
 
 		server := httptest.NewServer(router)
@@ -432,8 +424,7 @@ func TestExposeHeaders(t *testing.T) {
 	}))
 
 	router.Get("/a", func(c *looli.Context) {
-		c.Status(statusCode)
-		c.String(serverResponse)
+		c.String(statusCode, serverResponse)
 	})
 
 	server := httptest.NewServer(router)
@@ -471,8 +462,7 @@ func TestMaxAge(t *testing.T) {
 	}))
 
 	router.Get("/a", func(c *looli.Context) {
-		c.Status(statusCode)
-		c.String(serverResponse)
+		c.String(statusCode, serverResponse)
 	})
 
 	server := httptest.NewServer(router)
@@ -535,8 +525,7 @@ func TestAllowHeaders(t *testing.T) {
 	}))
 
 	router.Get("/a", func(c *looli.Context) {
-		c.Status(statusCode)
-		c.String(serverResponse)
+		c.String(statusCode, serverResponse)
 	})
 
 	server := httptest.NewServer(router)
@@ -597,8 +586,7 @@ func TestRequestMethodEmpty(t *testing.T) {
 	router.Use(New(Options{}))
 
 	router.Get("/a", func(c *looli.Context) {
-		c.Status(statusCode)
-		c.String(serverResponse)
+		c.String(statusCode, serverResponse)
 	})
 
 	server := httptest.NewServer(router)
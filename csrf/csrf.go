@@ -68,8 +68,8 @@ func New(options Options) looli.HandlerFunc {
 		}
 
 		if csrfToken == "" || !verify(getSecret(c), csrfToken) {
-			c.AbortWithStatus(http.StatusForbidden)
-			c.String(invalidCsrfTokenResponse)
+			c.Abort()
+			c.String(http.StatusForbidden, invalidCsrfTokenResponse)
 			return
 		}
 	}
@@ -19,8 +19,7 @@ func TestCsrfMiddleWare(t *testing.T) {
 
 		router.Use(Default())
 		router.Get("/", func(ctx *looli.Context) {
-			ctx.Status(statusCode)
-			ctx.String(serverResponse)
+			ctx.String(statusCode, serverResponse)
 		})
 
 		server := httptest.NewServer(router)
@@ -55,12 +54,11 @@ func TestCsrfMiddleWare(t *testing.T) {
 
 		router.Get("/", func(ctx *looli.Context) {
 			token := NewToken(ctx)
-			ctx.String(token)
+			ctx.String(http.StatusOK, token)
 		})
 
 		router.Post("/", func(ctx *looli.Context) {
-			ctx.Status(statusCode)
-			ctx.String(serverResponse)
+			ctx.String(statusCode, serverResponse)
 		})
 
 		server := httptest.NewServer(router)
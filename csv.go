@@ -0,0 +1,307 @@
+package looli
+
+import (
+	"context"
+	"encoding"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var csvContentType = []string{"text/csv; charset=utf-8"}
+
+// utf8BOM lets spreadsheet applications such as Excel detect the encoding of CSV.
+const utf8BOM = "\ufeff"
+
+// CSVRender renders Data as CSV. Data can be a slice, a channel or an Iterator of structs
+// or []string. Columns of structs are named by the "csv" tag or the field name, fields
+// tagged with "-" are skipped and embedded structs are flattened.
+//
+//	type User struct {
+//		ID      int       `csv:"id"`
+//		Name    string    `csv:"name"`
+//		Created time.Time `csv:"created_at"`
+//		Secret  string    `csv:"-"`
+//	}
+type CSVRender struct {
+	Data interface{}
+
+	// Header is written as the first row, it defaults to the column names of structs.
+	Header []string
+
+	// Comma is the field delimiter, default is ','.
+	Comma rune
+
+	// BOM writes the UTF-8 byte order mark before the content.
+	BOM bool
+
+	// Filename is set in Content-Disposition header so that the response is downloaded.
+	Filename string
+}
+
+// csvColumn is a column of the CSV rendered from struct.
+type csvColumn struct {
+	name  string
+	index []int
+}
+
+func (r CSVRender) Render(w http.ResponseWriter) error {
+	return r.render(context.Background(), w)
+}
+
+func (r CSVRender) WriteContentType(w http.ResponseWriter) {
+	setContentType(w, csvContentType)
+	if r.Filename != "" {
+		w.Header().Set("Content-Disposition", contentDisposition("attachment", r.Filename))
+	}
+}
+
+// render writes the rows until Data is exhausted or ctx is done, the rows of channels and
+// Iterators are flushed to the client as soon as they are written.
+func (r CSVRender) render(ctx context.Context, w http.ResponseWriter) error {
+	iterate, err := iterator(r.Data)
+	if err != nil {
+		return err
+	}
+
+	if r.BOM {
+		if _, err := io.WriteString(w, utf8BOM); err != nil {
+			return err
+		}
+	}
+
+	writer := csv.NewWriter(w)
+	if r.Comma != 0 {
+		writer.Comma = r.Comma
+	}
+
+	header := r.Header
+	var columns []csvColumn
+	if typ := csvStructType(reflect.TypeOf(r.Data)); typ != nil {
+		columns = csvColumns(typ, nil)
+		if header == nil {
+			header = csvColumnNames(columns)
+		}
+	}
+	if header != nil {
+		if err := writer.Write(header); err != nil {
+			return err
+		}
+	}
+
+	kind := reflect.ValueOf(r.Data).Kind()
+	streaming := kind != reflect.Slice && kind != reflect.Array
+	flusher, _ := w.(http.Flusher)
+
+	err = iterate(ctx, func(item interface{}) error {
+		record, ok := item.([]string)
+		if !ok {
+			val := reflect.Indirect(reflect.ValueOf(item))
+			if val.Kind() != reflect.Struct {
+				return fmt.Errorf("can not render %T as csv row, it must be struct or []string", item)
+			}
+
+			if columns == nil {
+				columns = csvColumns(val.Type(), nil)
+				if header == nil {
+					header = csvColumnNames(columns)
+					if err := writer.Write(header); err != nil {
+						return err
+					}
+				}
+			}
+			record = csvRecord(val, columns)
+		}
+
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+		if streaming {
+			writer.Flush()
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		return writer.Error()
+	})
+
+	writer.Flush()
+	if err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+// csvStructType returns the struct type of the elements of a slice, array or channel type.
+func csvStructType(typ reflect.Type) reflect.Type {
+	if typ == nil {
+		return nil
+	}
+
+	switch typ.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Chan:
+		typ = typ.Elem()
+	default:
+		return nil
+	}
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct || isCSVValue(typ) {
+		return nil
+	}
+	return typ
+}
+
+// csvColumns returns the columns of struct type typ, index is the index of typ in its parent.
+func csvColumns(typ reflect.Type, index []int) []csvColumn {
+	var columns []csvColumn
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag := field.Tag.Get("csv")
+		if tag == "-" || (field.PkgPath != "" && !field.Anonymous) {
+			continue
+		}
+
+		fieldIndex := append(append([]int(nil), index...), i)
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && tag == "" && fieldType.Kind() == reflect.Struct && !isCSVValue(fieldType) {
+			columns = append(columns, csvColumns(fieldType, fieldIndex)...)
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name := field.Name
+		if tag != "" {
+			name = tag
+		}
+		columns = append(columns, csvColumn{name: name, index: fieldIndex})
+	}
+	return columns
+}
+
+var (
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	stringerType      = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+)
+
+// isCSVValue reports whether struct type typ is written as a single value by csvValue, rather
+// than flattened to columns.
+func isCSVValue(typ reflect.Type) bool {
+	return typ == timeType || implements(typ, textMarshalerType) || implements(typ, stringerType)
+}
+
+func csvColumnNames(columns []csvColumn) []string {
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = column.name
+	}
+	return names
+}
+
+// csvRecord returns the values of columns of val, a field behind nil pointer is empty.
+func csvRecord(val reflect.Value, columns []csvColumn) []string {
+	record := make([]string, len(columns))
+	for i, column := range columns {
+		field := val
+		for _, index := range column.index {
+			if field.Kind() == reflect.Ptr {
+				if field.IsNil() {
+					field = reflect.Value{}
+					break
+				}
+				field = field.Elem()
+			}
+			field = field.Field(index)
+		}
+		record[i] = csvValue(field)
+	}
+	return record
+}
+
+// csvValue formats val as a CSV field.
+func csvValue(val reflect.Value) string {
+	for val.IsValid() && (val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface) {
+		if val.IsNil() {
+			return ""
+		}
+		val = val.Elem()
+	}
+	if !val.IsValid() {
+		return ""
+	}
+
+	if val.CanInterface() {
+		switch value := val.Interface().(type) {
+		case time.Time:
+			if value.IsZero() {
+				return ""
+			}
+			return value.Format(time.RFC3339)
+		case encoding.TextMarshaler:
+			text, err := value.MarshalText()
+			if err == nil {
+				return string(text)
+			}
+		case fmt.Stringer:
+			return value.String()
+		}
+	}
+
+	switch val.Kind() {
+	case reflect.String:
+		return val.String()
+	case reflect.Bool:
+		return strconv.FormatBool(val.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(val.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(val.Uint(), 10)
+	case reflect.Float32:
+		return strconv.FormatFloat(val.Float(), 'f', -1, 32)
+	case reflect.Float64:
+		return strconv.FormatFloat(val.Float(), 'f', -1, 64)
+	case reflect.Slice, reflect.Array:
+		values := make([]string, val.Len())
+		for i := range values {
+			values[i] = csvValue(val.Index(i))
+		}
+		return strings.Join(values, ";")
+	}
+
+	if !val.CanInterface() {
+		return ""
+	}
+	return fmt.Sprint(val.Interface())
+}
+
+// CSV writes status code and data as CSV, data can be a slice, a channel or an Iterator of
+// structs or []string. If filename is not empty the response is downloaded as filename.
+// The rows of channels and Iterators are flushed as soon as they are written, it stops when
+// the client disconnects and returns the error. Use Render with CSVRender to set the
+// delimiter or write the BOM.
+func (c *Context) CSV(code int, filename string, data interface{}) error {
+	// nothing is written if data can't be iterated, so the error can still be responded
+	if _, err := iterator(data); err != nil {
+		return err
+	}
+
+	r := CSVRender{Data: data, Filename: filename}
+	r.WriteContentType(c.ResponseWriter)
+	c.Status(code)
+
+	if !bodyAllowedForStatus(code) {
+		return nil
+	}
+	return r.render(c.Request.Context(), c.ResponseWriter)
+}
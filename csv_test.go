@@ -0,0 +1,162 @@
+package looli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type csvBase struct {
+	ID int `csv:"id"`
+}
+
+type csvUser struct {
+	csvBase
+	Name    string    `csv:"name"`
+	Tags    []string  `csv:"tags"`
+	Score   *float64  `csv:"score"`
+	Created time.Time `csv:"created_at"`
+	Active  bool
+	Secret  string `csv:"-"`
+	private string
+}
+
+func TestCSV(t *testing.T) {
+	score := 9.5
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	users := []csvUser{
+		{csvBase{1}, "alice", []string{"a", "b"}, &score, created, true, "secret", ""},
+		{csvBase{2}, "bob, \"jr\"", nil, nil, time.Time{}, false, "secret", ""},
+	}
+	usersCSV := "id,name,tags,score,created_at,Active\n" +
+		"1,alice,a;b,9.5,2020-01-02T03:04:05Z,true\n" +
+		"2,\"bob, \"\"jr\"\"\",,,,false\n"
+
+	router := New()
+	router.Get("/users", func(c *Context) {
+		assert.Nil(t, c.CSV(http.StatusOK, "users.csv", users))
+	})
+	router.Get("/channel", func(c *Context) {
+		rows := make(chan *csvUser, 2)
+		rows <- &users[0]
+		rows <- &users[1]
+		close(rows)
+		assert.Nil(t, c.CSV(http.StatusOK, "", rows))
+	})
+	router.Get("/iterator", func(c *Context) {
+		count := 0
+		assert.Nil(t, c.CSV(http.StatusOK, "", Iterator(func() (interface{}, bool) {
+			if count == len(users) {
+				return nil, false
+			}
+			count++
+			return users[count-1], true
+		})))
+	})
+	router.Get("/empty", func(c *Context) {
+		assert.Nil(t, c.CSV(http.StatusOK, "", []csvUser{}))
+	})
+	router.Get("/records", func(c *Context) {
+		c.Render(http.StatusOK, CSVRender{
+			Data:   [][]string{{"1", "a"}, {"2", "b"}},
+			Header: []string{"id", "name"},
+			Comma:  ';',
+			BOM:    true,
+		})
+	})
+	router.Get("/invalid", func(c *Context) {
+		assert.NotNil(t, c.CSV(http.StatusOK, "", []int{1}))
+	})
+	router.Get("/not-iterable", func(c *Context) {
+		err := c.CSV(http.StatusOK, "", 1)
+		assert.NotNil(t, err)
+		assert.False(t, c.Written())
+		c.String(http.StatusInternalServerError, err.Error())
+	})
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	cases := []struct {
+		path        string
+		disposition string
+		body        string
+	}{
+		{"/users", `attachment; filename="users.csv"`, usersCSV},
+		{"/channel", "", usersCSV},
+		{"/iterator", "", usersCSV},
+		{"/empty", "", "id,name,tags,score,created_at,Active\n"},
+		{"/records", "", "\ufeffid;name\n1;a\n2;b\n"},
+		{"/invalid", "", ""},
+	}
+
+	for _, tc := range cases {
+		resp, err := http.Get(server.URL + tc.path)
+		assert.Nil(t, err)
+		bodyBytes, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Nil(t, err)
+		assert.Equal(t, csvContentType[0], resp.Header.Get("Content-Type"), tc.path)
+		assert.Equal(t, tc.disposition, resp.Header.Get("Content-Disposition"), tc.path)
+		assert.Equal(t, tc.body, string(bodyBytes), tc.path)
+	}
+	resp, err := http.Get(server.URL + "/not-iterable")
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Equal(t, plainContentType[0], resp.Header.Get("Content-Type"))
+}
+
+// CSVMoney is written as a single value since it is a fmt.Stringer.
+type CSVMoney struct {
+	Cents int
+}
+
+func (m CSVMoney) String() string {
+	return fmt.Sprintf("%d.%02d", m.Cents/100, m.Cents%100)
+}
+
+func TestCSVValueStruct(t *testing.T) {
+	type order struct {
+		csvBase
+		Total CSVMoney `csv:"total"`
+	}
+
+	typ := reflect.TypeOf(order{})
+	assert.Equal(t, typ, csvStructType(reflect.TypeOf([]*order{})))
+	assert.Nil(t, csvStructType(reflect.TypeOf([]CSVMoney{})))
+	assert.Nil(t, csvStructType(reflect.TypeOf([]time.Time{})))
+
+	columns := csvColumns(typ, nil)
+	assert.Equal(t, []string{"id", "total"}, csvColumnNames(columns))
+	assert.Equal(t, []string{"1", "12.05"}, csvRecord(reflect.ValueOf(order{csvBase{1}, CSVMoney{1205}}), columns))
+}
+
+func TestNegotiateCSV(t *testing.T) {
+	router := New()
+	router.Get("/users", func(c *Context) {
+		c.Negotiate(http.StatusOK, Negotiate{
+			Offered: []string{MIMEJSON, MIMECSV},
+			Data:    []csvBase{{1}, {2}},
+		})
+	})
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/users", nil)
+	req.Header.Set("Accept", "text/csv")
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Nil(t, err)
+	assert.Equal(t, csvContentType[0], resp.Header.Get("Content-Type"))
+	assert.Equal(t, "id\n1\n2\n", string(bodyBytes))
+}
@@ -0,0 +1,168 @@
+package looli
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// defaultMaxDecompressedSize is the maximum size of decompressed request body.
+const defaultMaxDecompressedSize = 10 << 20
+
+var (
+	// ErrUnsupportedContentEncoding is responded by the Decompress middleware when the
+	// Content-Encoding of request body is not supported.
+	ErrUnsupportedContentEncoding = errors.New("unsupported content encoding")
+
+	// ErrMalformedBody is responded by the Decompress middleware when the request body can't
+	// be decompressed as its Content-Encoding.
+	ErrMalformedBody = errors.New("malformed compressed request body")
+)
+
+// DecompressOptions configures the Decompress middleware.
+type DecompressOptions struct {
+	// MaxSize is the maximum number of bytes of decompressed body, default is 10MB. Reading
+	// beyond it returns *http.MaxBytesError, so Bind and BodyBytes return an *Error with Code
+	// http.StatusRequestEntityTooLarge.
+	MaxSize int64
+}
+
+// Decompress returns a middleware which decompresses the request body encoded with gzip or
+// deflate as Content-Encoding header describes, with default options.
+func Decompress() HandlerFunc {
+	return DecompressWithOptions(DecompressOptions{})
+}
+
+// DecompressWithOptions returns a middleware which decompresses the request body encoded with
+// gzip or deflate as Content-Encoding header describes, so Bind reads the decompressed body.
+// The request is aborted with http.StatusUnsupportedMediaType if the encoding is not supported,
+// or http.StatusBadRequest if the body is malformed. The size of decompressed body is limited
+// to protect against decompression bombs.
+func DecompressWithOptions(options DecompressOptions) HandlerFunc {
+	if options.MaxSize <= 0 {
+		options.MaxSize = defaultMaxDecompressedSize
+	}
+
+	return func(c *Context) {
+		req := c.Request
+		contentEncoding := req.Header.Get("Content-Encoding")
+		if contentEncoding == "" || req.Body == nil || req.Body == http.NoBody {
+			c.Next()
+			return
+		}
+
+		// the codings are listed in the order they were applied
+		var codings []string
+		for _, coding := range strings.Split(contentEncoding, ",") {
+			coding = strings.ToLower(strings.TrimSpace(coding))
+			switch coding {
+			case "", "identity":
+			case "gzip", "x-gzip", "deflate":
+				codings = append(codings, coding)
+			default:
+				c.ResponseWriter.Header().Set("Accept-Encoding", "gzip, deflate")
+				c.AbortWithError(http.StatusUnsupportedMediaType, &Error{
+					Err:  ErrUnsupportedContentEncoding,
+					Code: http.StatusUnsupportedMediaType,
+					Meta: coding,
+				})
+				return
+			}
+		}
+
+		body := &decompressedBody{body: req.Body, remaining: options.MaxSize, limit: options.MaxSize}
+		var reader io.Reader = req.Body
+		for i := len(codings) - 1; i >= 0; i-- {
+			decoder, err := newDecoder(codings[i], reader)
+			if err != nil {
+				c.AbortWithError(http.StatusBadRequest, &Error{
+					Err:  ErrMalformedBody,
+					Code: http.StatusBadRequest,
+					Meta: err.Error(),
+				})
+				return
+			}
+			body.decoders = append(body.decoders, decoder)
+			reader = decoder
+		}
+		body.reader = reader
+
+		req.Body = body
+		req.Header.Del("Content-Encoding")
+		req.Header.Del("Content-Length")
+		req.ContentLength = -1
+		c.Next()
+	}
+}
+
+// newDecoder returns the reader decompressing r as coding.
+func newDecoder(coding string, r io.Reader) (io.ReadCloser, error) {
+	if coding == "deflate" {
+		// deflate is defined as zlib format, but some clients send raw deflate data
+		br := bufio.NewReader(r)
+		header, err := br.Peek(2)
+		if err != nil {
+			return nil, err
+		}
+		if isZlibHeader(header) {
+			return zlib.NewReader(br)
+		}
+		return flate.NewReader(br), nil
+	}
+	return gzip.NewReader(r)
+}
+
+// isZlibHeader reports whether header is the header of zlib format, RFC 1950.
+func isZlibHeader(header []byte) bool {
+	return header[0]&0x0f == 8 && header[0]>>4 <= 7 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0
+}
+
+// decompressedBody reads the decompressed body, at most limit bytes are read.
+type decompressedBody struct {
+	body      io.ReadCloser
+	decoders  []io.ReadCloser
+	reader    io.Reader
+	remaining int64
+	limit     int64
+	err       error
+}
+
+func (b *decompressedBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	// read one more byte to detect the body is larger than limit
+	if int64(len(p))-1 > b.remaining {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.reader.Read(p)
+	if int64(n) <= b.remaining {
+		b.remaining -= int64(n)
+		if err != nil && !errors.Is(err, io.EOF) {
+			err = &Error{Err: ErrMalformedBody, Code: http.StatusBadRequest, Meta: err.Error()}
+		}
+		b.err = err
+		return n, err
+	}
+
+	n = int(b.remaining)
+	b.remaining = 0
+	b.err = &http.MaxBytesError{Limit: b.limit}
+	return n, b.err
+}
+
+func (b *decompressedBody) Close() error {
+	for _, decoder := range b.decoders {
+		decoder.Close()
+	}
+	return b.body.Close()
+}
@@ -0,0 +1,130 @@
+package looli
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func compressBody(t *testing.T, coding string, data []byte) []byte {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch coding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "zlib":
+		w = zlib.NewWriter(&buf)
+	case "flate":
+		var err error
+		w, err = flate.NewWriter(&buf, flate.DefaultCompression)
+		assert.Nil(t, err)
+	}
+	_, err := w.Write(data)
+	assert.Nil(t, err)
+	assert.Nil(t, w.Close())
+	return buf.Bytes()
+}
+
+type decompressUser struct {
+	Name string `json:"name"`
+}
+
+func (*decompressUser) Validate() error {
+	return nil
+}
+
+func abortWithBindError(c *Context, err error) {
+	code := http.StatusBadRequest
+	if e, ok := err.(*Error); ok {
+		code = e.Code
+	}
+	c.AbortWithError(code, err)
+}
+
+func TestDecompress(t *testing.T) {
+	router := New()
+	router.Use(DecompressWithOptions(DecompressOptions{MaxSize: 1024}))
+	router.Post("/users", func(c *Context) {
+		var user decompressUser
+		if err := c.Bind(&user); err != nil {
+			abortWithBindError(c, err)
+			return
+		}
+		assert.Empty(t, c.Header("Content-Encoding"))
+		c.String(http.StatusOK, user.Name)
+	})
+	router.Post("/body", func(c *Context) {
+		body, err := c.BodyBytes()
+		if err != nil {
+			abortWithBindError(c, err)
+			return
+		}
+		c.String(http.StatusOK, "%d", len(body))
+	})
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	user := []byte(`{"name":"looli"}`)
+	gzipped := compressBody(t, "gzip", user)
+	bomb := compressBody(t, "gzip", []byte(`{"name":"`+strings.Repeat("a", 100000)+`"}`))
+	cases := []struct {
+		path            string
+		contentEncoding string
+		body            []byte
+		statusCode      int
+		response        string
+	}{
+		{"/users", "", user, http.StatusOK, "looli"},
+		{"/users", "identity", user, http.StatusOK, "looli"},
+		{"/users", "gzip", gzipped, http.StatusOK, "looli"},
+		{"/users", "X-GZIP", gzipped, http.StatusOK, "looli"},
+		{"/users", "deflate", compressBody(t, "zlib", user), http.StatusOK, "looli"},
+		{"/users", "deflate", compressBody(t, "flate", user), http.StatusOK, "looli"},
+		{"/users", "deflate, gzip", compressBody(t, "gzip", compressBody(t, "zlib", user)), http.StatusOK, "looli"},
+		{"/users", "br", user, http.StatusUnsupportedMediaType, ErrUnsupportedContentEncoding.Error()},
+		{"/users", "gzip, compress", user, http.StatusUnsupportedMediaType, ErrUnsupportedContentEncoding.Error()},
+		{"/users", "gzip", user, http.StatusBadRequest, ErrMalformedBody.Error()},
+		{"/users", "gzip", gzipped[:len(gzipped)/2], http.StatusBadRequest, ErrMalformedBody.Error()},
+		{"/users", "gzip", bomb, http.StatusRequestEntityTooLarge, ErrBodyTooLarge.Error()},
+		{"/body", "gzip", gzipped, http.StatusOK, "16"},
+		{"/body", "gzip", bomb, http.StatusRequestEntityTooLarge, ErrBodyTooLarge.Error()},
+		{"/body", "gzip", compressBody(t, "gzip", bytes.Repeat([]byte("a"), 1024)), http.StatusOK, "1024"},
+		{"/body", "gzip", compressBody(t, "gzip", bytes.Repeat([]byte("a"), 1025)), http.StatusRequestEntityTooLarge, ErrBodyTooLarge.Error()},
+	}
+	for _, tc := range cases {
+		name := tc.path + " " + tc.contentEncoding + " " + tc.response
+		req, err := http.NewRequest(http.MethodPost, server.URL+tc.path, bytes.NewReader(tc.body))
+		assert.Nil(t, err)
+		req.Header.Set("Content-Type", MIMEJSON)
+		req.Header.Set("Content-Encoding", tc.contentEncoding)
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Nil(t, err)
+
+		assert.Equal(t, tc.statusCode, resp.StatusCode, name)
+		if tc.statusCode == http.StatusOK {
+			assert.Equal(t, tc.response, string(body), name)
+			continue
+		}
+
+		var envelope Envelope
+		assert.Nil(t, json.Unmarshal(body, &envelope), name)
+		assert.Equal(t, tc.response, envelope.Msg, name)
+		if tc.statusCode == http.StatusUnsupportedMediaType {
+			assert.Equal(t, "gzip, deflate", resp.Header.Get("Accept-Encoding"), name)
+		}
+	}
+}
@@ -8,19 +8,16 @@ Package looli is a minimalist web framework for go
 
     v1 := router.Prefix("/v1")
     v1.Get("/a", func(c *looli.Context) {
-        c.Status(200)
-        c.String("hello world version1\n")
+        c.String(200, "hello world version1\n")
     })
 
     v2 := router.Prefix("/v2")
     v2.Get("/a", func(c *looli.Context) {
-        c.Status(200)
-        c.String("hello world version2\n")
+        c.String(200, "hello world version2\n")
     })
 
     router.Get("/a", func(c *looli.Context) {
-        c.Status(200)
-        c.String("hello world!\n")
+        c.String(200, "hello world!\n")
     })
 */
 package looli
@@ -0,0 +1,184 @@
+package looli
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Attachment replies with the contents of the named file as an attachment, the browser
+// saves it as filename instead of displaying it. filename defaults to the base name of
+// the file, non-ASCII names are encoded as RFC 6266 describes. Range requests are
+// supported so downloads can be resumed.
+func (c *Context) Attachment(filepath, filename string) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		c.fileError(err)
+		return
+	}
+	defer file.Close()
+
+	c.serveFile(file, filename, true)
+}
+
+// FileFromFS replies with the contents of the named file in fsys, such as embed.FS. The
+// Content-Type is detected from the extension of name or the content, a missing file or a
+// directory is responded with 404.
+func (c *Context) FileFromFS(name string, fsys fs.FS) {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		name = "."
+	}
+
+	file, err := fsys.Open(name)
+	if err != nil {
+		c.fileError(err)
+		return
+	}
+	defer file.Close()
+
+	c.serveFile(file, "", false)
+}
+
+// serveFile replies with the contents of file, it is downloaded as filename if attachment
+// is true, filename defaults to the base name of file.
+func (c *Context) serveFile(file fs.File, filename string, attachment bool) {
+	stat, err := file.Stat()
+	if err != nil {
+		c.fileError(err)
+		return
+	}
+	if stat.IsDir() {
+		c.String(http.StatusNotFound, default404Body)
+		return
+	}
+
+	content, ok := file.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(file)
+		if err != nil {
+			c.fileError(err)
+			return
+		}
+		content = bytes.NewReader(data)
+	}
+
+	if filename == "" {
+		filename = stat.Name()
+	}
+	if attachment {
+		c.SetHeader("Content-Disposition", contentDisposition("attachment", filename))
+	}
+	http.ServeContent(c.ResponseWriter, c.Request, filename, stat.ModTime(), content)
+}
+
+// fileError responds with the status code matching the error of opening a file.
+func (c *Context) fileError(err error) {
+	switch {
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, fs.ErrInvalid):
+		c.String(http.StatusNotFound, default404Body)
+	case errors.Is(err, fs.ErrPermission):
+		c.String(http.StatusForbidden, default403Body)
+	default:
+		c.String(http.StatusInternalServerError, default500Body)
+	}
+}
+
+// DataFromReader writes status code and the content of reader with contentType and headers.
+// The Content-Length header is set if contentLength is not negative, otherwise large bodies
+// are sent chunked. It returns the error of copying, which is usually caused by the client
+// disconnecting.
+//
+//	c.DataFromReader(http.StatusOK, resp.ContentLength, resp.Header.Get("Content-Type"), resp.Body, map[string]string{
+//		"Content-Disposition": `attachment; filename="report.csv"`,
+//	})
+func (c *Context) DataFromReader(code int, contentLength int64, contentType string, reader io.Reader, headers map[string]string) error {
+	header := c.ResponseWriter.Header()
+	for key, value := range headers {
+		header.Set(key, value)
+	}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	if contentLength >= 0 {
+		header.Set("Content-Length", strconv.FormatInt(contentLength, 10))
+	}
+	c.Status(code)
+
+	if !bodyAllowedForStatus(code) || c.Request.Method == http.MethodHead {
+		return nil
+	}
+	_, err := io.Copy(c.ResponseWriter, reader)
+	return err
+}
+
+// ServeContent replies with content, it handles Range, If-Range, If-Match, If-None-Match,
+// If-Modified-Since and If-Unmodified-Since headers, so generated content can be
+// downloaded in parts and resumed. The Content-Type is detected from the extension of name
+// or the content if it is not set. Set the ETag header before calling it for If-Range to
+// match on the ETag, otherwise modtime is used if it is not zero.
+func (c *Context) ServeContent(name string, modtime time.Time, content io.ReadSeeker) {
+	http.ServeContent(c.ResponseWriter, c.Request, name, modtime, content)
+}
+
+// contentDisposition returns the Content-Disposition header with filename. A quoted ASCII
+// fallback is always present, filename* in RFC 5987 encoding is added for other names.
+func contentDisposition(disposition, filename string) string {
+	filename = filepath.Base(filename)
+
+	fallback := make([]byte, 0, len(filename))
+	ascii := true
+	for i := 0; i < len(filename); i++ {
+		b := filename[i]
+		switch {
+		case b < 0x20 || b >= 0x7f:
+			ascii = false
+			if len(fallback) == 0 || fallback[len(fallback)-1] != '_' {
+				fallback = append(fallback, '_')
+			}
+		case b == '"' || b == '\\':
+			fallback = append(fallback, '\\', b)
+		default:
+			fallback = append(fallback, b)
+		}
+	}
+
+	value := disposition + `; filename="` + string(fallback) + `"`
+	if !ascii {
+		value += "; filename*=UTF-8''" + encodeRFC5987(filename)
+	}
+	return value
+}
+
+// encodeRFC5987 percent encodes the bytes of value except attr-char of RFC 5987.
+func encodeRFC5987(value string) string {
+	const hex = "0123456789ABCDEF"
+
+	var buf strings.Builder
+	for i := 0; i < len(value); i++ {
+		b := value[i]
+		if isAttrChar(b) {
+			buf.WriteByte(b)
+			continue
+		}
+		buf.WriteByte('%')
+		buf.WriteByte(hex[b>>4])
+		buf.WriteByte(hex[b&0xf])
+	}
+	return buf.String()
+}
+
+func isAttrChar(b byte) bool {
+	if 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9' {
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", b) >= 0
+}
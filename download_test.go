@@ -0,0 +1,165 @@
+package looli
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttachment(t *testing.T) {
+	router := New()
+	router.Get("/download", func(c *Context) {
+		c.Attachment("test/index.html", c.Query("name"))
+	})
+	router.Get("/missing", func(c *Context) {
+		c.Attachment("test/missing.html", "")
+	})
+	router.Get("/dir", func(c *Context) {
+		c.Attachment("test", "")
+	})
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	content, err := ioutil.ReadFile("test/index.html")
+	assert.Nil(t, err)
+
+	cases := []struct {
+		name        string
+		disposition string
+	}{
+		{"", `attachment; filename="index.html"`},
+		{"report.html", `attachment; filename="report.html"`},
+		{`a"b.html`, `attachment; filename="a\"b.html"`},
+		{"报告 2024.html", `attachment; filename="_ 2024.html"; filename*=UTF-8''%E6%8A%A5%E5%91%8A%202024.html`},
+	}
+	for _, tc := range cases {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/download", nil)
+		query := req.URL.Query()
+		query.Set("name", tc.name)
+		req.URL.RawQuery = query.Encode()
+
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		bodyBytes, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, tc.disposition, resp.Header.Get("Content-Disposition"))
+		assert.Equal(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
+		assert.Equal(t, content, bodyBytes)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/download", nil)
+	req.Header.Set("Range", "bytes=0-4")
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	assert.Equal(t, content[:5], bodyBytes)
+
+	for _, path := range []string{"/missing", "/dir"} {
+		resp, err := http.Get(server.URL + path)
+		assert.Nil(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		assert.Equal(t, "", resp.Header.Get("Content-Disposition"))
+	}
+}
+
+func TestDataFromReader(t *testing.T) {
+	router := New()
+	router.Get("/sized", func(c *Context) {
+		err := c.DataFromReader(http.StatusCreated, 5, "text/csv", strings.NewReader("a,b\n1"), map[string]string{
+			"Content-Disposition": `attachment; filename="report.csv"`,
+		})
+		assert.Nil(t, err)
+	})
+	router.Get("/chunked", func(c *Context) {
+		err := c.DataFromReader(http.StatusOK, -1, "", strings.NewReader("chunked"), nil)
+		assert.Nil(t, err)
+	})
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/sized")
+	assert.Nil(t, err)
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, int64(5), resp.ContentLength)
+	assert.Equal(t, "text/csv", resp.Header.Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="report.csv"`, resp.Header.Get("Content-Disposition"))
+	assert.Equal(t, "a,b\n1", string(bodyBytes))
+
+	resp, err = http.Get(server.URL + "/chunked")
+	assert.Nil(t, err)
+	bodyBytes, err = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Nil(t, err)
+	assert.Equal(t, "chunked", string(bodyBytes))
+}
+
+func TestServeContent(t *testing.T) {
+	content := "0123456789"
+	modtime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	router := New()
+	router.Get("/report", func(c *Context) {
+		c.SetHeader("ETag", `"v1"`)
+		c.ServeContent("report.txt", modtime, strings.NewReader(content))
+	})
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	cases := []struct {
+		rangeHeader string
+		ifRange     string
+		statusCode  int
+		body        string
+	}{
+		{"", "", http.StatusOK, content},
+		{"bytes=2-5", "", http.StatusPartialContent, "2345"},
+		{"bytes=-3", "", http.StatusPartialContent, "789"},
+		{"bytes=5-", `"v1"`, http.StatusPartialContent, "56789"},
+		{"bytes=5-", `"v0"`, http.StatusOK, content},
+		{"bytes=20-", "", http.StatusRequestedRangeNotSatisfiable, ""},
+	}
+
+	for _, tc := range cases {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/report", nil)
+		if tc.rangeHeader != "" {
+			req.Header.Set("Range", tc.rangeHeader)
+		}
+		if tc.ifRange != "" {
+			req.Header.Set("If-Range", tc.ifRange)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		bodyBytes, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Nil(t, err)
+		assert.Equal(t, tc.statusCode, resp.StatusCode, tc.rangeHeader)
+		if tc.statusCode != http.StatusRequestedRangeNotSatisfiable {
+			assert.Equal(t, tc.body, string(bodyBytes), tc.rangeHeader)
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/report", nil)
+	req.Header.Set("If-None-Match", `"v1"`)
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+}
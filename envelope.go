@@ -0,0 +1,121 @@
+package looli
+
+// Envelope is the content of a response written by Context.SetBody, Context.SetResult
+// and Context.AbortWithError, an EnvelopeBuilder turns it into the JSON body.
+type Envelope struct {
+	// Code is the result code, 0 for success.
+	Code int
+
+	// Msg describe the result, "ok" for success.
+	Msg string
+
+	// Data is the payload set by SetBody.
+	Data interface{}
+
+	// HasData is set when the envelope is written by SetBody, Data is included even if it is nil.
+	HasData bool
+
+	// Err is set when the envelope is written by AbortWithError.
+	Err *Error
+
+	// Meta holds extra information such as request id, pagination and trace info, it is
+	// filled by EnvelopeHook and Context.SetEnvelopeMeta.
+	Meta map[string]interface{}
+}
+
+// EnvelopeBuilder returns the JSON body for envelope.
+type EnvelopeBuilder func(*Context, *Envelope) interface{}
+
+// EnvelopeHook is called before the envelope is built, it is used to add meta information.
+type EnvelopeHook func(*Context, *Envelope)
+
+// defaultEnvelope builds {"code": 0, "msg": "ok", "data": ...}, data is only included for
+// SetBody, meta information is added as top level fields.
+func defaultEnvelope(c *Context, envelope *Envelope) interface{} {
+	body := make(map[string]interface{}, len(envelope.Meta)+3)
+	for key, value := range envelope.Meta {
+		body[key] = value
+	}
+
+	body["code"] = envelope.Code
+	body["msg"] = envelope.Msg
+	if envelope.HasData {
+		body["data"] = envelope.Data
+	}
+	return body
+}
+
+// SetEnvelope sets the builder used by SetBody, SetResult and AbortWithError, default
+// builds {"code": 0, "msg": "ok", "data": ...}.
+func (engine *Engine) SetEnvelope(builder EnvelopeBuilder) {
+	if builder == nil {
+		panic("envelope builder can not be nil")
+	}
+
+	engine.envelope = builder
+}
+
+// AddEnvelopeHook adds hooks called before the envelope is built, for example to add the
+// request id:
+//
+//	router.AddEnvelopeHook(func(c *looli.Context, e *looli.Envelope) {
+//		e.Meta["request_id"] = c.Header("X-Request-Id")
+//	})
+func (engine *Engine) AddEnvelopeHook(hooks ...EnvelopeHook) {
+	engine.envelopeHooks = append(engine.envelopeHooks, hooks...)
+}
+
+// NoEnvelope return a middleware which opts the routes out of the envelope, SetBody writes
+// data as it is, SetResult and AbortWithError use the default envelope without hooks.
+func NoEnvelope() HandlerFunc {
+	return func(c *Context) {
+		c.noEnvelope = true
+	}
+}
+
+// SetEnvelopeMeta sets meta information of the envelope written by this request, such as pagination.
+func (c *Context) SetEnvelopeMeta(key string, value interface{}) {
+	if c.envelopeMeta == nil {
+		c.envelopeMeta = make(map[string]interface{})
+	}
+	c.envelopeMeta[key] = value
+}
+
+// AbortWithError records err, prevents pending handlers from being called, and writes status
+// code and err in the envelope. The code of envelope is err's code if err is *Error with non
+// zero Code, otherwise it is status code.
+func (c *Context) AbortWithError(code int, err error) {
+	c.Error(err)
+	c.Abort()
+
+	envelopeCode := c.Err.Code
+	if envelopeCode == 0 {
+		envelopeCode = code
+	}
+	c.renderEnvelope(code, &Envelope{
+		Code: envelopeCode,
+		Msg:  c.Err.Error(),
+		Err:  c.Err,
+	})
+}
+
+func (c *Context) renderEnvelope(code int, envelope *Envelope) {
+	if c.noEnvelope {
+		c.JSON(code, defaultEnvelope(c, envelope))
+		return
+	}
+
+	envelope.Meta = make(map[string]interface{}, len(c.envelopeMeta))
+	for key, value := range c.envelopeMeta {
+		envelope.Meta[key] = value
+	}
+	for _, hook := range c.engine.envelopeHooks {
+		hook(c, envelope)
+	}
+
+	builder := c.engine.envelope
+	if builder == nil {
+		builder = defaultEnvelope
+	}
+	c.JSON(code, builder(c, envelope))
+}
@@ -0,0 +1,138 @@
+package looli
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvelope(t *testing.T) {
+	getBody := func(t *testing.T, router *Engine, path string) (int, string) {
+		server := httptest.NewServer(router)
+		defer server.Close()
+
+		resp, err := http.Get(server.URL + path)
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		bodyBytes, err := ioutil.ReadAll(resp.Body)
+		assert.Nil(t, err)
+		return resp.StatusCode, string(bodyBytes)
+	}
+
+	t.Run("default envelope", func(t *testing.T) {
+		router := New()
+		router.Get("/body", func(c *Context) {
+			c.SetBody(http.StatusOK, []int{1})
+		})
+		router.Get("/result", func(c *Context) {
+			c.SetResult(http.StatusOK, 10001, "invalid name")
+		})
+		router.Get("/error", func(c *Context) {
+			c.AbortWithError(http.StatusBadRequest, errors.New("invalid age"))
+		})
+		router.Get("/error/code", func(c *Context) {
+			c.AbortWithError(http.StatusUnprocessableEntity, &Error{Err: errors.New("invalid email"), Code: 10002})
+		})
+		router.Get("/nil", func(c *Context) {
+			c.SetBody(http.StatusOK, nil)
+		})
+		router.Get("/meta", func(c *Context) {
+			c.SetEnvelopeMeta("total", 100)
+			c.SetBody(http.StatusOK, []int{1})
+		})
+
+		status, body := getBody(t, router, "/body")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, `{"code":0,"data":[1],"msg":"ok"}`+"\n", body)
+
+		_, body = getBody(t, router, "/nil")
+		assert.Equal(t, `{"code":0,"data":null,"msg":"ok"}`+"\n", body)
+
+		_, body = getBody(t, router, "/result")
+		assert.Equal(t, `{"code":10001,"msg":"invalid name"}`+"\n", body)
+
+		status, body = getBody(t, router, "/error")
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Equal(t, `{"code":400,"msg":"invalid age"}`+"\n", body)
+
+		// error envelopes carry no data
+		status, body = getBody(t, router, "/error/code")
+		assert.Equal(t, http.StatusUnprocessableEntity, status)
+		assert.Equal(t, `{"code":10002,"msg":"invalid email"}`+"\n", body)
+
+		_, body = getBody(t, router, "/meta")
+		assert.Equal(t, `{"code":0,"data":[1],"msg":"ok","total":100}`+"\n", body)
+	})
+
+	t.Run("custom envelope", func(t *testing.T) {
+		router := New()
+		router.SetEnvelope(func(c *Context, e *Envelope) interface{} {
+			body := JSON{
+				"status": "ok",
+				"data":   e.Data,
+				"meta":   e.Meta,
+			}
+			if e.Err != nil {
+				body["status"] = "error"
+				body["error"] = e.Msg
+			}
+			return body
+		})
+		router.AddEnvelopeHook(func(c *Context, e *Envelope) {
+			e.Meta["request_id"] = c.Header("X-Request-Id")
+		})
+		router.Get("/body", func(c *Context) {
+			c.SetEnvelopeMeta("page", 2)
+			c.SetBody(http.StatusOK, "hello")
+		})
+		router.Get("/error", func(c *Context) {
+			c.AbortWithError(http.StatusNotFound, &Error{Err: errors.New("not found"), Code: 40401})
+		})
+
+		server := httptest.NewServer(router)
+		defer server.Close()
+
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/body", nil)
+		assert.Nil(t, err)
+		req.Header.Set("X-Request-Id", "abc")
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		bodyBytes, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Nil(t, err)
+		assert.Equal(t, `{"data":"hello","meta":{"page":2,"request_id":"abc"},"status":"ok"}`+"\n", string(bodyBytes))
+
+		status, body := getBody(t, router, "/error")
+		assert.Equal(t, http.StatusNotFound, status)
+		assert.Equal(t, `{"data":null,"error":"not found","meta":{"request_id":""},"status":"error"}`+"\n", body)
+	})
+
+	t.Run("no envelope", func(t *testing.T) {
+		router := New()
+		router.SetEnvelope(func(c *Context, e *Envelope) interface{} {
+			return JSON{"wrapped": e.Data}
+		})
+		raw := router.Prefix("/raw")
+		raw.Use(NoEnvelope())
+		raw.Get("/body", func(c *Context) {
+			c.SetBody(http.StatusOK, []int{1})
+		})
+		raw.Get("/result", func(c *Context) {
+			c.SetResult(http.StatusOK, 1, "failed")
+		})
+		router.Get("/body", func(c *Context) {
+			c.SetBody(http.StatusOK, []int{1})
+		})
+
+		_, body := getBody(t, router, "/raw/body")
+		assert.Equal(t, "[1]\n", body)
+		_, body = getBody(t, router, "/raw/result")
+		assert.Equal(t, `{"code":1,"msg":"failed"}`+"\n", body)
+		_, body = getBody(t, router, "/body")
+		assert.Equal(t, `{"wrapped":[1]}`+"\n", body)
+	})
+}
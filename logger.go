@@ -1,39 +1,462 @@
 package looli
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
+	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"text/template"
 	"time"
 )
 
 var defaultWriter = os.Stdout
 
+// defaultRequestIDHeader is the header which the request ID is read from.
+const defaultRequestIDHeader = "X-Request-Id"
+
+// LogParams are the fields of an access log entry.
+type LogParams struct {
+	// Request is the request being logged
+	Request *http.Request
+
+	// TimeStamp is the time the response was finished
+	TimeStamp time.Time
+
+	// Latency is the time spent to handle the request
+	Latency time.Duration
+
+	ClientIP   string
+	Method     string
+	Path       string
+	RequestURI string
+	Proto      string
+
+	// Route is the route pattern matched by the request, such as "/users/:id"
+	Route string
+
+	// StatusCode is the status code of the response
+	StatusCode int
+
+	// BodySize is the number of bytes written to the response body
+	BodySize int
+
+	UserAgent string
+	Referer   string
+
+	// User is the user name of basic authentication
+	User string
+
+	// RequestID is read from the request header, or the response header if the request
+	// doesn't have it, see LoggerOptions.RequestIDHeader
+	RequestID string
+
+	// Err is the error of Context when processing request
+	Err *Error
+
+	// ErrorMessage is the message of Err
+	ErrorMessage string
+
+	// Slow reports whether Latency exceeds LoggerOptions.SlowThreshold
+	Slow bool
+
+	// Color reports whether the entry is written with ANSI colors, see LoggerOptions.Color
+	Color bool
+}
+
+const (
+	colorReset   = "\033[0m"
+	colorRed     = "\033[97;41m"
+	colorGreen   = "\033[97;42m"
+	colorYellow  = "\033[90;43m"
+	colorBlue    = "\033[97;44m"
+	colorMagenta = "\033[97;45m"
+	colorCyan    = "\033[97;46m"
+	colorWhite   = "\033[90;47m"
+)
+
+// StatusColor returns the ANSI color of status code, it is empty if Color is false.
+func (p LogParams) StatusColor() string {
+	if !p.Color {
+		return ""
+	}
+	switch {
+	case p.StatusCode >= http.StatusInternalServerError:
+		return colorRed
+	case p.StatusCode >= http.StatusBadRequest:
+		return colorYellow
+	case p.StatusCode >= http.StatusMultipleChoices:
+		return colorWhite
+	default:
+		return colorGreen
+	}
+}
+
+// MethodColor returns the ANSI color of method, it is empty if Color is false.
+func (p LogParams) MethodColor() string {
+	if !p.Color {
+		return ""
+	}
+	switch p.Method {
+	case http.MethodGet:
+		return colorBlue
+	case http.MethodPost:
+		return colorCyan
+	case http.MethodPut:
+		return colorYellow
+	case http.MethodDelete:
+		return colorRed
+	case http.MethodPatch:
+		return colorGreen
+	case http.MethodHead:
+		return colorMagenta
+	default:
+		return colorWhite
+	}
+}
+
+// ResetColor returns the ANSI code which resets the color, it is empty if Color is false.
+func (p LogParams) ResetColor() string {
+	if !p.Color {
+		return ""
+	}
+	return colorReset
+}
+
+// LogFormatter formats an access log entry as a line.
+type LogFormatter func(params LogParams) string
+
+// LoggerOptions configures the Logger middleware.
+type LoggerOptions struct {
+	// Output is the writer which the formatted entries are written to, default is os.Stdout.
+	Output io.Writer
+
+	// Formatter formats the entries, default is DefaultLogFormatter. CommonLogFormatter,
+	// CombinedLogFormatter and JSONLogFormatter are builtin, LogTemplate creates a formatter
+	// from a template.
+	Formatter LogFormatter
+
+	// Slog receives the entries as structured records instead of Output, the level is
+	// Error for status 5xx, Warn for 4xx and slow requests, and Info for others.
+	Slog *slog.Logger
+
+	// RequestIDHeader is the header which the request ID is read from, default is X-Request-Id.
+	RequestIDHeader string
+
+	// SkipPaths are the request paths or route patterns, such as "/healthz" or "/users/:id",
+	// which are not logged.
+	SkipPaths []string
+
+	// SkipStatusCodes are the status codes which are not logged.
+	SkipStatusCodes []int
+
+	// SampleRate logs 1 in SampleRate successful requests, requests with status code 4xx
+	// or 5xx, requests with Context.Err and slow requests are always logged. All requests are
+	// logged if it is less than 2.
+	SampleRate int
+
+	// SlowThreshold marks requests taking longer than it as slow, the level of slow requests
+	// is raised to Warn with Slog. Zero disables it.
+	SlowThreshold time.Duration
+
+	// Color writes the status code and method with ANSI colors if Output is a terminal.
+	Color bool
+
+	// Clock returns the current time which the time stamp and latency are measured with,
+	// default is time.Now.
+	Clock func() time.Time
+}
+
+// Logger returns a middleware which writes access logs to os.Stdout with DefaultLogFormatter.
 func Logger() HandlerFunc {
 	return LoggerWithWriter(defaultWriter)
 }
 
+// LoggerWithWriter returns a middleware which writes access logs to out with DefaultLogFormatter.
 func LoggerWithWriter(out io.Writer) HandlerFunc {
+	return LoggerWithOptions(LoggerOptions{Output: out})
+}
+
+// LoggerWithFormatter returns a middleware which writes access logs formatted by formatter
+// to os.Stdout.
+//
+//	router.Use(looli.LoggerWithFormatter(looli.CombinedLogFormatter))
+func LoggerWithFormatter(formatter LogFormatter) HandlerFunc {
+	return LoggerWithOptions(LoggerOptions{Formatter: formatter})
+}
+
+// LoggerWithOptions returns a middleware which writes access logs as options configures.
+//
+//	router.Use(looli.LoggerWithOptions(looli.LoggerOptions{
+//		Slog: slog.New(slog.NewJSONHandler(os.Stderr, nil)),
+//	}))
+func LoggerWithOptions(options LoggerOptions) HandlerFunc {
+	if options.Output == nil {
+		options.Output = defaultWriter
+	}
+	if options.Formatter == nil {
+		options.Formatter = DefaultLogFormatter
+	}
+	if options.RequestIDHeader == "" {
+		options.RequestIDHeader = defaultRequestIDHeader
+	}
+	if options.Clock == nil {
+		options.Clock = time.Now
+	}
+
+	skipPaths := make(map[string]bool, len(options.SkipPaths))
+	for _, path := range options.SkipPaths {
+		skipPaths[path] = true
+	}
+	skipStatusCodes := make(map[int]bool, len(options.SkipStatusCodes))
+	for _, code := range options.SkipStatusCodes {
+		skipStatusCodes[code] = true
+	}
+	color := options.Color && options.Slog == nil && isTerminal(options.Output)
+	var sampled uint64
+
 	return func(c *Context) {
-		start := time.Now()
-		path := c.Path
-		method := c.Request.Method
+		start := options.Clock()
 		c.Next()
 
-		end := time.Now()
-		latency := end.Sub(start)
-		clientIP := c.ClientIP()
-		statusCode := c.statusCode
-		proto := c.Request.Proto
-
-		fmt.Fprintf(out, "[looli] %v | %3d | %11v | %s | %-4s %-8s %s\n",
-			end.Format("2006/01/01 - 15:04:05"),
-			statusCode,
-			latency,
-			clientIP,
-			method,
-			proto,
-			path,
-		)
+		if skipPaths[c.Path] || skipPaths[c.Pattern()] || skipStatusCodes[c.StatusCode()] {
+			return
+		}
+
+		params := newLogParams(c, options.RequestIDHeader, start, options.Clock())
+		params.Slow = options.SlowThreshold > 0 && params.Latency > options.SlowThreshold
+		params.Color = color
+		if options.SampleRate > 1 && params.StatusCode < http.StatusBadRequest && params.Err == nil && !params.Slow {
+			if (atomic.AddUint64(&sampled, 1)-1)%uint64(options.SampleRate) != 0 {
+				return
+			}
+		}
+
+		if options.Slog != nil {
+			logAttrs(options.Slog, params)
+			return
+		}
+		io.WriteString(options.Output, options.Formatter(params))
+	}
+}
+
+// isTerminal reports whether w is a terminal.
+func isTerminal(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func newLogParams(c *Context, requestIDHeader string, start, end time.Time) LogParams {
+	req := c.Request
+	params := LogParams{
+		Request:    req,
+		TimeStamp:  end,
+		Latency:    end.Sub(start),
+		ClientIP:   c.ClientIP(),
+		Method:     req.Method,
+		Path:       c.Path,
+		RequestURI: req.RequestURI,
+		Proto:      req.Proto,
+		Route:      c.Pattern(),
+		StatusCode: c.StatusCode(),
+		BodySize:   c.Size(),
+		UserAgent:  req.UserAgent(),
+		Referer:    req.Referer(),
+		Err:        c.Err,
+	}
+	if params.RequestURI == "" {
+		params.RequestURI = req.URL.RequestURI()
+	}
+	if user, _, ok := req.BasicAuth(); ok {
+		params.User = user
+	}
+
+	params.RequestID = req.Header.Get(requestIDHeader)
+	if params.RequestID == "" {
+		params.RequestID = c.ResponseWriter.Header().Get(requestIDHeader)
+	}
+	if c.Err != nil && c.Err.Err != nil {
+		params.ErrorMessage = c.Err.Error()
+	}
+	return params
+}
+
+// DefaultLogFormatter formats the entry as
+//
+//	[looli] 2006/01/02 - 15:04:05 | 200 |    1.234ms | 127.0.0.1 | GET  HTTP/1.1 /users/1
+//
+// followed by " | slow" for slow requests and the error message if any.
+func DefaultLogFormatter(params LogParams) string {
+	line := fmt.Sprintf("[looli] %v |%s %3d %s| %11v | %s |%s %-4s%s %-8s %s",
+		params.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		params.StatusColor(), params.StatusCode, params.ResetColor(),
+		params.Latency,
+		params.ClientIP,
+		params.MethodColor(), params.Method, params.ResetColor(),
+		params.Proto,
+		params.Path,
+	)
+	if params.Slow {
+		line += " | slow"
+	}
+	if params.ErrorMessage != "" {
+		line += " | " + params.ErrorMessage
+	}
+	return line + "\n"
+}
+
+// CommonLogFormatter formats the entry in Common Log Format of Apache and nginx.
+//
+//	127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326
+func CommonLogFormatter(params LogParams) string {
+	return commonLogFormat(params) + "\n"
+}
+
+// CombinedLogFormatter formats the entry in Combined Log Format of Apache and nginx, which
+// is Common Log Format with referer and user agent.
+//
+//	127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08"
+func CombinedLogFormatter(params LogParams) string {
+	return commonLogFormat(params) + " " + strconv.Quote(params.Referer) + " " + strconv.Quote(params.UserAgent) + "\n"
+}
+
+func commonLogFormat(params LogParams) string {
+	size := "-"
+	if params.BodySize > 0 {
+		size = strconv.Itoa(params.BodySize)
+	}
+	return fmt.Sprintf("%s - %s [%s] %s %d %s",
+		logField(params.ClientIP),
+		logField(params.User),
+		params.TimeStamp.Format("02/Jan/2006:15:04:05 -0700"),
+		strconv.Quote(params.Method+" "+params.RequestURI+" "+params.Proto),
+		params.StatusCode,
+		size,
+	)
+}
+
+// logField returns "-" for empty field.
+func logField(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// jsonLogEntry is the entry written by JSONLogFormatter.
+type jsonLogEntry struct {
+	Time      string  `json:"time"`
+	Status    int     `json:"status"`
+	Latency   float64 `json:"latency_ms"`
+	ClientIP  string  `json:"client_ip"`
+	User      string  `json:"user,omitempty"`
+	Method    string  `json:"method"`
+	Path      string  `json:"path"`
+	URI       string  `json:"uri"`
+	Route     string  `json:"route,omitempty"`
+	Proto     string  `json:"proto"`
+	Bytes     int     `json:"bytes"`
+	UserAgent string  `json:"user_agent,omitempty"`
+	Referer   string  `json:"referer,omitempty"`
+	RequestID string  `json:"request_id,omitempty"`
+	Error     string  `json:"error,omitempty"`
+	Slow      bool    `json:"slow,omitempty"`
+}
+
+// JSONLogFormatter formats the entry as a JSON object in a line, the latency is in
+// milliseconds.
+//
+//	{"time":"2006-01-02T15:04:05.999Z","status":200,"latency_ms":1.234,"client_ip":"127.0.0.1","method":"GET","path":"/users/1","uri":"/users/1?a=1","route":"/users/:id","proto":"HTTP/1.1","bytes":42}
+func JSONLogFormatter(params LogParams) string {
+	entry := jsonLogEntry{
+		Time:      params.TimeStamp.Format(time.RFC3339Nano),
+		Status:    params.StatusCode,
+		Latency:   float64(params.Latency) / float64(time.Millisecond),
+		ClientIP:  params.ClientIP,
+		User:      params.User,
+		Method:    params.Method,
+		Path:      params.Path,
+		URI:       params.RequestURI,
+		Route:     params.Route,
+		Proto:     params.Proto,
+		Bytes:     params.BodySize,
+		UserAgent: params.UserAgent,
+		Referer:   params.Referer,
+		RequestID: params.RequestID,
+		Error:     params.ErrorMessage,
+		Slow:      params.Slow,
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(entry); err != nil {
+		return fmt.Sprintf("{\"error\":%q}\n", err.Error())
+	}
+	return buf.String()
+}
+
+// LogTemplate returns a LogFormatter which executes the text/template with LogParams, it
+// panics if text can't be parsed. A newline is appended if the output doesn't end with it.
+//
+//	looli.LogTemplate(`{{ .ClientIP }} {{ .Method }} {{ .Path }} {{ .StatusCode }} {{ .Latency }}`)
+func LogTemplate(text string) LogFormatter {
+	templ := template.Must(template.New("log").Parse(text))
+	return func(params LogParams) string {
+		var buf strings.Builder
+		if err := templ.Execute(&buf, params); err != nil {
+			return fmt.Sprintf("[looli] log template: %v\n", err)
+		}
+		if line := buf.String(); !strings.HasSuffix(line, "\n") {
+			buf.WriteString("\n")
+		}
+		return buf.String()
+	}
+}
+
+// logAttrs writes the entry to logger as a structured record.
+func logAttrs(logger *slog.Logger, params LogParams) {
+	level := slog.LevelInfo
+	switch {
+	case params.StatusCode >= http.StatusInternalServerError:
+		level = slog.LevelError
+	case params.StatusCode >= http.StatusBadRequest, params.Slow:
+		level = slog.LevelWarn
+	}
+
+	attrs := []slog.Attr{
+		slog.Int("status", params.StatusCode),
+		slog.String("method", params.Method),
+		slog.String("path", params.Path),
+		slog.String("uri", params.RequestURI),
+		slog.String("route", params.Route),
+		slog.String("proto", params.Proto),
+		slog.String("client_ip", params.ClientIP),
+		slog.Duration("latency", params.Latency),
+		slog.Int("bytes", params.BodySize),
+		slog.String("user_agent", params.UserAgent),
+		slog.String("referer", params.Referer),
+	}
+	if params.User != "" {
+		attrs = append(attrs, slog.String("user", params.User))
+	}
+	if params.RequestID != "" {
+		attrs = append(attrs, slog.String("request_id", params.RequestID))
+	}
+	if params.ErrorMessage != "" {
+		attrs = append(attrs, slog.String("error", params.ErrorMessage))
+	}
+	if params.Slow {
+		attrs = append(attrs, slog.Bool("slow", true))
 	}
+	logger.LogAttrs(params.Request.Context(), level, "request", attrs...)
 }
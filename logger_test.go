@@ -2,9 +2,16 @@ package looli
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -52,3 +59,316 @@ func issueRequest(t *testing.T, router *Engine, method, path string) {
 	}
 	defer resp.Body.Close()
 }
+
+func loggerRequest(t *testing.T, router *Engine, method, path string, header http.Header) {
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	req, err := http.NewRequest(method, server.URL+path, nil)
+	assert.Nil(t, err)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	resp.Body.Close()
+}
+
+func newLoggerRouter(options LoggerOptions) *Engine {
+	router := New()
+	router.Use(LoggerWithOptions(options))
+	router.Get("/users/:id", func(c *Context) {
+		c.String(http.StatusOK, "user %s", c.Param("id"))
+	})
+	router.Get("/error", func(c *Context) {
+		c.SetHeader("X-Request-Id", "from-response")
+		c.AbortWithError(http.StatusInternalServerError, errors.New("database is down"))
+	})
+	return router
+}
+
+func TestLoggerDefaultFormat(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	router := newLoggerRouter(LoggerOptions{Output: buffer})
+
+	loggerRequest(t, router, http.MethodGet, "/users/1", nil)
+	line := buffer.String()
+	assert.True(t, strings.HasPrefix(line, "[looli] "+time.Now().Format("2006/01/02")), line)
+	assert.Contains(t, line, "| 200 |")
+	assert.Contains(t, line, "GET  HTTP/1.1 /users/1\n")
+
+	buffer.Reset()
+	loggerRequest(t, router, http.MethodGet, "/error", nil)
+	assert.Contains(t, buffer.String(), "| 500 |")
+	assert.True(t, strings.HasSuffix(buffer.String(), "/error | database is down\n"), buffer.String())
+}
+
+func TestLoggerFormatters(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	header := http.Header{
+		"Referer":    {"http://example.com/start"},
+		"User-Agent": {"looli-test"},
+	}
+
+	router := newLoggerRouter(LoggerOptions{Output: buffer, Formatter: CommonLogFormatter})
+	loggerRequest(t, router, http.MethodGet, "/users/1?a=1", header)
+	assert.Regexp(t, `^127\.0\.0\.1 - - \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "GET /users/1\?a=1 HTTP/1\.1" 200 6\n$`, buffer.String())
+
+	buffer.Reset()
+	router = newLoggerRouter(LoggerOptions{Output: buffer, Formatter: CombinedLogFormatter})
+	basicAuth := http.Header{"Authorization": {"Basic Zm9vOmJhcg=="}}
+	for key, values := range header {
+		basicAuth[key] = values
+	}
+	loggerRequest(t, router, http.MethodGet, "/error", basicAuth)
+	assert.Regexp(t, `^127\.0\.0\.1 - foo \[.+\] "GET /error HTTP/1\.1" 500 \d+ "http://example\.com/start" "looli-test"\n$`, buffer.String())
+
+	buffer.Reset()
+	router = newLoggerRouter(LoggerOptions{Output: buffer, Formatter: JSONLogFormatter})
+	loggerRequest(t, router, http.MethodGet, "/users/1", http.Header{"X-Request-Id": {"abc"}, "User-Agent": {"looli-test"}})
+	assert.True(t, strings.HasSuffix(buffer.String(), "}\n"))
+	var entry map[string]interface{}
+	assert.Nil(t, json.Unmarshal(buffer.Bytes(), &entry))
+	assert.Equal(t, float64(200), entry["status"])
+	assert.Equal(t, "/users/1", entry["path"])
+	assert.Equal(t, "/users/:id", entry["route"])
+	assert.Equal(t, float64(6), entry["bytes"])
+	assert.Equal(t, "abc", entry["request_id"])
+	assert.Equal(t, "looli-test", entry["user_agent"])
+	assert.Equal(t, "127.0.0.1", entry["client_ip"])
+	assert.Equal(t, "/users/1", entry["uri"])
+	assert.NotContains(t, entry, "user")
+	assert.NotContains(t, entry, "error")
+	_, err := time.Parse(time.RFC3339Nano, entry["time"].(string))
+	assert.Nil(t, err)
+
+	buffer.Reset()
+	loggerRequest(t, router, http.MethodGet, "/error?retry=1", basicAuth)
+	entry = nil
+	assert.Nil(t, json.Unmarshal(buffer.Bytes(), &entry))
+	assert.Equal(t, "database is down", entry["error"])
+	assert.Equal(t, "from-response", entry["request_id"])
+	assert.Equal(t, "foo", entry["user"])
+	assert.Equal(t, "/error?retry=1", entry["uri"])
+
+	buffer.Reset()
+	router = newLoggerRouter(LoggerOptions{
+		Output:    buffer,
+		Formatter: LogTemplate(`{{ .Method }} {{ .Route }} {{ .StatusCode }} {{ .BodySize }} {{ .RequestID }}`),
+	})
+	loggerRequest(t, router, http.MethodGet, "/users/12", http.Header{"X-Request-Id": {"abc"}})
+	assert.Equal(t, "GET /users/:id 200 7 abc\n", buffer.String())
+
+	buffer.Reset()
+	loggerRequest(t, router, http.MethodGet, "/missing", nil)
+	assert.Equal(t, "GET  404 19 \n", buffer.String())
+
+	assert.Panics(t, func() {
+		LogTemplate(`{{ .Method `)
+	})
+}
+
+func TestLoggerRequestIDHeader(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	router := newLoggerRouter(LoggerOptions{
+		Output:          buffer,
+		RequestIDHeader: "X-Trace-Id",
+		Formatter:       LogTemplate(`{{ .RequestID }}`),
+	})
+	loggerRequest(t, router, http.MethodGet, "/users/1", http.Header{"X-Trace-Id": {"trace"}, "X-Request-Id": {"abc"}})
+	assert.Equal(t, "trace\n", buffer.String())
+}
+
+func TestLoggerSlog(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	logger := slog.New(slog.NewJSONHandler(buffer, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	output := new(bytes.Buffer)
+	router := newLoggerRouter(LoggerOptions{Output: output, Slog: logger})
+
+	cases := []struct {
+		path   string
+		level  string
+		status float64
+	}{
+		{"/users/1", "INFO", 200},
+		{"/missing", "WARN", 404},
+		{"/error", "ERROR", 500},
+	}
+	for _, tc := range cases {
+		buffer.Reset()
+		loggerRequest(t, router, http.MethodGet, tc.path, http.Header{"X-Request-Id": {"abc"}})
+
+		var record map[string]interface{}
+		assert.Nil(t, json.Unmarshal(buffer.Bytes(), &record), tc.path)
+		assert.Equal(t, "request", record["msg"])
+		assert.Equal(t, tc.level, record["level"], tc.path)
+		assert.Equal(t, tc.status, record["status"], tc.path)
+		assert.Equal(t, tc.path, record["path"], tc.path)
+		assert.Equal(t, "abc", record["request_id"], tc.path)
+		assert.Contains(t, record, "latency")
+		assert.Contains(t, record, "bytes")
+		assert.NotContains(t, record, "user")
+		if tc.path == "/error" {
+			assert.Equal(t, "database is down", record["error"])
+		} else {
+			assert.NotContains(t, record, "error")
+		}
+	}
+
+	// the basic auth user and the query string are logged
+	buffer.Reset()
+	loggerRequest(t, router, http.MethodGet, "/users/1?tab=posts", http.Header{"Authorization": {"Basic Zm9vOmJhcg=="}})
+	var record map[string]interface{}
+	assert.Nil(t, json.Unmarshal(buffer.Bytes(), &record))
+	assert.Equal(t, "/users/1", record["path"])
+	assert.Equal(t, "/users/1?tab=posts", record["uri"])
+	assert.Equal(t, "/users/:id", record["route"])
+	assert.Equal(t, "foo", record["user"])
+	assert.Empty(t, output.String())
+}
+
+func TestLoggerSkip(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	router := newLoggerRouter(LoggerOptions{
+		Output:          buffer,
+		SkipPaths:       []string{"/healthz", "/users/:id"},
+		SkipStatusCodes: []int{http.StatusNotFound},
+		Formatter:       LogTemplate(`{{ .Path }}`),
+	})
+	router.Get("/healthz", func(c *Context) {
+		c.Status(http.StatusOK)
+	})
+
+	loggerRequest(t, router, http.MethodGet, "/healthz", nil)
+	loggerRequest(t, router, http.MethodGet, "/users/1", nil)
+	loggerRequest(t, router, http.MethodGet, "/missing", nil)
+	loggerRequest(t, router, http.MethodGet, "/error", nil)
+	assert.Equal(t, "/error\n", buffer.String())
+}
+
+func TestLoggerSampling(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	router := newLoggerRouter(LoggerOptions{
+		Output:     buffer,
+		SampleRate: 3,
+		Formatter:  LogTemplate(`{{ .StatusCode }}`),
+	})
+
+	for i := 0; i < 7; i++ {
+		loggerRequest(t, router, http.MethodGet, "/users/1", nil)
+	}
+	assert.Equal(t, "200\n200\n200\n", buffer.String())
+
+	// errors are always logged
+	router.Get("/warning", func(c *Context) {
+		c.Error(errors.New("cache is down"))
+		c.String(http.StatusOK, "ok")
+	})
+	buffer.Reset()
+	for i := 0; i < 3; i++ {
+		loggerRequest(t, router, http.MethodGet, "/missing", nil)
+		loggerRequest(t, router, http.MethodGet, "/error", nil)
+		loggerRequest(t, router, http.MethodGet, "/warning", nil)
+	}
+	assert.Equal(t, strings.Repeat("404\n500\n200\n", 3), buffer.String())
+}
+
+func TestLoggerSlow(t *testing.T) {
+	// the clock only advances in the slow handlers
+	now := time.Now()
+	clock := func() time.Time {
+		return now
+	}
+	slow := func(c *Context) {
+		now = now.Add(2 * time.Second)
+	}
+
+	buffer := new(bytes.Buffer)
+	options := LoggerOptions{
+		Output:        buffer,
+		SampleRate:    100,
+		SlowThreshold: time.Second,
+		Clock:         clock,
+	}
+	router := newLoggerRouter(options)
+	router.Get("/slow", slow)
+
+	// the first request is sampled, slow requests are always logged
+	loggerRequest(t, router, http.MethodGet, "/users/1", nil)
+	assert.True(t, strings.HasSuffix(buffer.String(), "/users/1\n"), buffer.String())
+	buffer.Reset()
+	loggerRequest(t, router, http.MethodGet, "/users/1", nil)
+	assert.Empty(t, buffer.String())
+	loggerRequest(t, router, http.MethodGet, "/slow", nil)
+	assert.True(t, strings.HasSuffix(buffer.String(), "/slow | slow\n"), buffer.String())
+	assert.Contains(t, buffer.String(), "|          2s |")
+
+	buffer.Reset()
+	options.Formatter = JSONLogFormatter
+	router = newLoggerRouter(options)
+	router.Get("/slow", slow)
+	loggerRequest(t, router, http.MethodGet, "/slow", nil)
+	var entry map[string]interface{}
+	assert.Nil(t, json.Unmarshal(buffer.Bytes(), &entry))
+	assert.Equal(t, true, entry["slow"])
+	assert.Equal(t, float64(2000), entry["latency_ms"])
+
+	buffer.Reset()
+	options.Slog = slog.New(slog.NewJSONHandler(buffer, nil))
+	router = newLoggerRouter(options)
+	router.Get("/slow", slow)
+	loggerRequest(t, router, http.MethodGet, "/slow", nil)
+	entry = nil
+	assert.Nil(t, json.Unmarshal(buffer.Bytes(), &entry))
+	assert.Equal(t, "WARN", entry["level"])
+	assert.Equal(t, true, entry["slow"])
+}
+
+func TestLoggerColor(t *testing.T) {
+	params := LogParams{
+		TimeStamp:  time.Now(),
+		ClientIP:   "127.0.0.1",
+		Method:     http.MethodPost,
+		Proto:      "HTTP/1.1",
+		Path:       "/users",
+		StatusCode: http.StatusInternalServerError,
+		Color:      true,
+	}
+	line := DefaultLogFormatter(params)
+	assert.Contains(t, line, "|"+colorRed+" 500 "+colorReset+"|")
+	assert.Contains(t, line, "|"+colorCyan+" POST"+colorReset+" HTTP/1.1 /users\n")
+
+	params.Color = false
+	assert.Empty(t, params.StatusColor())
+	assert.Empty(t, params.MethodColor())
+	assert.Empty(t, params.ResetColor())
+	assert.NotContains(t, DefaultLogFormatter(params), "\033[")
+
+	cases := []struct {
+		statusCode int
+		color      string
+	}{
+		{http.StatusOK, colorGreen},
+		{http.StatusFound, colorWhite},
+		{http.StatusNotFound, colorYellow},
+		{http.StatusServiceUnavailable, colorRed},
+	}
+	for _, tc := range cases {
+		params := LogParams{StatusCode: tc.statusCode, Color: true}
+		assert.Equal(t, tc.color, params.StatusColor(), strconv.Itoa(tc.statusCode))
+	}
+
+	// colors are only written to terminals
+	file, err := os.CreateTemp(t.TempDir(), "log")
+	assert.Nil(t, err)
+	defer file.Close()
+	assert.False(t, isTerminal(file))
+	assert.False(t, isTerminal(new(bytes.Buffer)))
+
+	router := newLoggerRouter(LoggerOptions{Output: file, Color: true})
+	loggerRequest(t, router, http.MethodGet, "/users/1", nil)
+	data, err := os.ReadFile(file.Name())
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), "| 200 |")
+	assert.NotContains(t, string(data), "\033[")
+}
@@ -3,6 +3,8 @@ package looli
 import (
 	"html/template"
 	"net/http"
+	"reflect"
+	"strings"
 )
 
 type (
@@ -17,8 +19,46 @@ type (
 		// X-Real-IP and X-Forwarded-For in order to work properly with reverse-proxies such us: nginx or haproxy.
 		ForwardedByClientIP bool
 
+		// when set true, misuse such as setting status code or header after the response was
+		// committed is reported to stderr with the caller's location.
+		Debug bool
+
 		// template used to render HTML
 		Template *template.Template
+
+		// HTMLRenderer used to render HTML, it takes precedence over Template
+		HTMLRenderer HTMLRenderer
+
+		// FuncMap and delimiters used to parse templates
+		funcMap template.FuncMap
+		delims  [2]string
+
+		// static directories with Fingerprint option, used by Asset
+		assets []*staticHandler
+
+		// NoRoute handlers, the single-page applications at the root are served before them
+		noRoute       []HandlerFunc
+		staticNoRoute []HandlerFunc
+
+		// prefix of Context.SecureJSON response, default is "while(1);"
+		SecureJSONPrefix string
+
+		// BindOptions used by Context.Bind, it can be overridden for specific routes with
+		// the WithBindOptions middleware.
+		BindOptions BindOptions
+
+		// Upgrader used by Context.Upgrade to upgrade connections to WebSocket
+		Upgrader Upgrader
+
+		// envelope builder and hooks used by Context.SetBody, SetResult and AbortWithError
+		envelope      EnvelopeBuilder
+		envelopeHooks []EnvelopeHook
+
+		// bindings used by Context.Bind, keyed by media type or structured syntax suffix
+		bindings map[string]Binding
+
+		// converters used by form binding, keyed by the type they convert to
+		converters map[reflect.Type]Converter
 	}
 	HandlerFunc func(*Context)
 )
@@ -31,27 +71,29 @@ func New() *Engine {
 	engine := &Engine{
 		RouterPrefix: RouterPrefix{},
 		router:       NewRouter(),
+		bindings:     defaultBindings(),
+		converters:   make(map[reflect.Type]Converter),
+		envelope:     defaultEnvelope,
 	}
 
 	engine.RouterPrefix.engine = engine
 	engine.RouterPrefix.router = engine.router
 	engine.router.IgnoreCase = false
 	engine.router.TrailingSlashRedirect = true
-	engine.router.NoRoute = []HandlerFunc{noRoute}
+	engine.noRoute = []HandlerFunc{noRoute}
+	engine.router.NoRoute = engine.noRoute
 	engine.router.NoMethod = []HandlerFunc{noMethod}
 	return engine
 }
 
 // noRoute use as a default handler for router not matched
 func noRoute(c *Context) {
-	c.Status(http.StatusNotFound)
-	c.String(default404Body)
+	c.String(http.StatusNotFound, default404Body)
 }
 
 // noMethod use as a default handler for Method not allowed
 func noMethod(c *Context) {
-	c.Status(http.StatusMethodNotAllowed)
-	c.String(default405Body)
+	c.String(http.StatusMethodNotAllowed, default405Body)
 }
 
 // Default return engine instance, add logger, recover handler to it.
@@ -67,7 +109,14 @@ func (engine *Engine) NoRoute(handlers ...HandlerFunc) {
 		panic("there must be at least one handler")
 	}
 
-	engine.router.NoRoute = handlers
+	engine.noRoute = handlers
+	engine.router.NoRoute = engine.combineNoRoute()
+}
+
+func (engine *Engine) combineNoRoute() []HandlerFunc {
+	handlers := make([]HandlerFunc, 0, len(engine.staticNoRoute)+len(engine.noRoute))
+	handlers = append(handlers, engine.staticNoRoute...)
+	return append(handlers, engine.noRoute...)
 }
 
 // NoMethod which is called when method is not registered. If it is not set, noMethod is used.
@@ -79,14 +128,38 @@ func (engine *Engine) NoMethod(handlers ...HandlerFunc) {
 	engine.router.NoMethod = handlers
 }
 
-func (engine *Engine) LoadHTMLGlob(pattern string) {
-	templ := template.Must(template.ParseGlob(pattern))
-	engine.Template = templ
+// RegisterBinding registers binding for the media type, mediaType can be a full media
+// type such as "application/json" or a structured syntax suffix such as "+json",
+// parameters of Content-Type are ignored when matching. A registered binding replaces
+// the previous one for the same media type.
+func (engine *Engine) RegisterBinding(mediaType string, binding Binding) {
+	if mediaType == "" {
+		panic("media type can not be empty")
+	}
+	if binding == nil {
+		panic("binding can not be nil")
+	}
+
+	engine.bindings[strings.ToLower(mediaType)] = binding
 }
 
-func (engine *Engine) LoadHTMLFiles(files ...string) {
-	templ := template.Must(template.ParseFiles(files...))
-	engine.Template = templ
+// RegisterConverter registers converter for the type of value, form and query values bound
+// to fields of this type are converted by converter. The value returned by converter
+// must be assignable to the type of value, for example:
+//
+//	router.RegisterConverter(UserID(0), func(s string) (interface{}, error) {
+//		id, err := strconv.ParseUint(strings.TrimPrefix(s, "u-"), 10, 64)
+//		return UserID(id), err
+//	})
+func (engine *Engine) RegisterConverter(value interface{}, converter Converter) {
+	if value == nil {
+		panic("value can not be nil")
+	}
+	if converter == nil {
+		panic("converter can not be nil")
+	}
+
+	engine.converters[reflect.TypeOf(value)] = converter
 }
 
 // set IgnoreCase value
@@ -69,8 +69,7 @@ func TestSetIgnoreCase(t *testing.T) {
 	router.SetIgnoreCase(false)
 	assert.False(t, router.router.IgnoreCase)
 	router.Get("/a/b", func(c *Context) {
-		c.Status(statusCode)
-		c.String(serverResponse)
+		c.String(statusCode, serverResponse)
 	})
 
 	server := httptest.NewServer(router)
@@ -102,8 +101,7 @@ func TestSetTrailingSlashRedirect(t *testing.T) {
 		statusCode := 200
 		statusNotFound := 404
 		router.Get("/a/b", func(c *Context) {
-			c.Status(statusCode)
-			c.String(serverResponse)
+			c.String(statusCode, serverResponse)
 		})
 
 		server := httptest.NewServer(router)
@@ -133,8 +131,7 @@ func TestSetTrailingSlashRedirect(t *testing.T) {
 		statusCode := 200
 		statusNotFound := 404
 		router.Get("/a/b/", func(c *Context) {
-			c.Status(statusCode)
-			c.String(serverResponse)
+			c.String(statusCode, serverResponse)
 		})
 
 		server := httptest.NewServer(router)
@@ -174,8 +171,7 @@ func TestNoMethod(t *testing.T) {
 		})
 
 		router.NoMethod(func(c *Context) {
-			c.Status(statusCode)
-			c.String(serverResponse)
+			c.String(statusCode, serverResponse)
 		})
 
 		server := httptest.NewServer(router)
@@ -228,8 +224,7 @@ func TestNoRoute(t *testing.T) {
 		})
 
 		router.NoRoute(func(c *Context) {
-			c.Status(statusCode)
-			c.String(serverResponse)
+			c.String(statusCode, serverResponse)
 		})
 
 		router.Get("/a/b", func(c *Context) {})
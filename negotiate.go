@@ -0,0 +1,161 @@
+package looli
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Negotiate describe the formats offered by Context.Negotiate.
+type Negotiate struct {
+	// Offered media types in order of preference, such as MIMEJSON, MIMEXML, MIMEHTML,
+	// MIMECSV and MIMEPlain, other media types must have a render in Renders.
+	Offered []string
+
+	// Data rendered by the builtin renders.
+	Data interface{}
+
+	// HTMLName is the template name used to render MIMEHTML.
+	HTMLName string
+
+	// Renders keyed by media type, they take precedence over the builtin renders.
+	Renders map[string]Render
+}
+
+// acceptRange is a media range of Accept header.
+type acceptRange struct {
+	typ     string
+	subtype string
+	q       float64
+}
+
+// Negotiate responds with the offered format which best matches the Accept header of the
+// request, Vary: Accept is always set. If none of the offered formats is acceptable, 406 Not
+// Acceptable is responded, a malformed Accept header falls back to the first offer.
+func (c *Context) Negotiate(code int, offers Negotiate) {
+	addVaryHeader(c.ResponseWriter.Header(), "Accept")
+
+	format := c.NegotiateFormat(offers.Offered...)
+	if format == "" {
+		c.String(http.StatusNotAcceptable, default406Body)
+		return
+	}
+
+	r, ok := offers.Renders[format]
+	if !ok {
+		switch format {
+		case MIMEJSON:
+			r = JSONRender{Data: offers.Data}
+		case MIMEXML, MIMEXML2:
+			r = XMLRender{Data: offers.Data}
+		case MIMEHTML:
+			r, code = c.htmlRender(code, offers.HTMLName, offers.Data)
+		case MIMECSV:
+			r = CSVRender{Data: offers.Data}
+		case MIMEPlain:
+			r = StringRender{Format: "%v", Data: []interface{}{offers.Data}}
+		default:
+			panic("no render for negotiated format " + format)
+		}
+	}
+	c.Render(code, r)
+}
+
+// NegotiateFormat returns the offered media type which best matches the Accept header of
+// the request. Media ranges are weighted by their q value, the most specific range matching
+// an offer decides its weight, between offers with the same weight the earlier one wins.
+// If the request has no Accept header, or none of its media ranges can be parsed, the first
+// offer is returned (RFC 9110), if none of the offers is acceptable "" is returned.
+func (c *Context) NegotiateFormat(offered ...string) string {
+	if len(offered) == 0 {
+		return ""
+	}
+
+	ranges := parseAccept(c.Header("Accept"))
+	if len(ranges) == 0 {
+		// a malformed Accept header is treated as absent
+		return offered[0]
+	}
+
+	best, bestQ := "", 0.0
+	for _, offer := range offered {
+		if q := acceptQuality(ranges, offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// parseAccept parses Accept header into media ranges, invalid ranges are ignored.
+func parseAccept(accept string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		mediaRange := strings.ToLower(strings.TrimSpace(params[0]))
+		index := strings.IndexByte(mediaRange, '/')
+		if index <= 0 || index == len(mediaRange)-1 {
+			continue
+		}
+
+		r := acceptRange{
+			typ:     mediaRange[:index],
+			subtype: mediaRange[index+1:],
+			q:       1,
+		}
+		if r.typ == "*" && r.subtype != "*" {
+			continue
+		}
+
+		for _, param := range params[1:] {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok || strings.ToLower(strings.TrimSpace(key)) != "q" {
+				continue
+			}
+			q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || q < 0 || q > 1 {
+				q = 0
+			}
+			r.q = q
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges
+}
+
+// acceptQuality returns the q value of the most specific media range matching offer.
+func acceptQuality(ranges []acceptRange, offer string) float64 {
+	offer = strings.ToLower(offer)
+	if index := strings.IndexByte(offer, ';'); index >= 0 {
+		offer = strings.TrimSpace(offer[:index])
+	}
+	typ, subtype, _ := strings.Cut(offer, "/")
+
+	q, specificity := 0.0, -1
+	for _, r := range ranges {
+		s := -1
+		switch {
+		case r.typ == typ && r.subtype == subtype:
+			s = 2
+		case r.typ == typ && r.subtype == "*":
+			s = 1
+		case r.typ == "*" && r.subtype == "*":
+			s = 0
+		}
+		if s > specificity {
+			q, specificity = r.q, s
+		}
+	}
+	return q
+}
+
+// addVaryHeader adds value to Vary header if it is not present yet.
+func addVaryHeader(header http.Header, value string) {
+	for _, vary := range header.Values("Vary") {
+		for _, v := range strings.Split(vary, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), value) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", value)
+}
@@ -0,0 +1,97 @@
+package looli
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	offered := []string{MIMEJSON, MIMEXML, MIMEHTML}
+	cases := map[string]string{
+		"":                                     MIMEJSON,
+		"*/*":                                  MIMEJSON,
+		"application/xml":                      MIMEXML,
+		"text/html, application/xml;q=0.9":     MIMEHTML,
+		"application/*;q=0.5, text/html;q=0.8": MIMEHTML,
+		"application/*":                        MIMEJSON,
+		"application/json;q=0, */*;q=0.1":      MIMEXML,
+		"TEXT/HTML":                            MIMEHTML,
+		"image/png":                            "",
+		"text/*;q=0, application/json;q=0":     "",
+		"application/json;q=abc, text/html":    MIMEHTML,
+		"*/json, application/xml;q=0.2":        MIMEXML,
+		"garbage":                              MIMEJSON,
+		"text, /html, */json, ;q=1":            MIMEJSON,
+	}
+
+	for accept, format := range cases {
+		req, err := http.NewRequest(http.MethodGet, "/", nil)
+		assert.Nil(t, err)
+		req.Header.Set("Accept", accept)
+		c := &Context{Request: req}
+		assert.Equal(t, format, c.NegotiateFormat(offered...), accept)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	router := New()
+	router.LoadHTMLGlob("test/templates/*")
+	router.Get("/", func(c *Context) {
+		c.Negotiate(http.StatusCreated, Negotiate{
+			Offered:  []string{MIMEJSON, MIMEXML, MIMEHTML, MIMEPlain, "text/csv"},
+			Data:     JSON{"title": "Posts"},
+			HTMLName: "index.tmpl",
+			Renders: map[string]Render{
+				MIMEXML:    XMLRender{Data: Info2{Name: "Posts"}},
+				"text/csv": StringRender{Format: "title\nPosts\n"},
+			},
+		})
+	})
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	cases := []struct {
+		accept      string
+		statusCode  int
+		contentType string
+		body        string
+	}{
+		{"application/json", http.StatusCreated, jsonContentType[0], `{"title":"Posts"}` + "\n"},
+		{"application/xml", http.StatusCreated, xmlContentType[0], "<person><name>Posts</name><age>0</age><other></other></person>"},
+		{"text/html", http.StatusCreated, htmlContentType[0], "<html>\n    <h1>\n        Posts\n    </h1>\n</html>"},
+		{"text/plain", http.StatusCreated, plainContentType[0], "map[title:Posts]"},
+		{"text/csv", http.StatusCreated, plainContentType[0], "title\nPosts\n"},
+		{"image/png", http.StatusNotAcceptable, plainContentType[0], default406Body},
+		{"garbage", http.StatusCreated, jsonContentType[0], `{"title":"Posts"}` + "\n"},
+	}
+
+	for _, tc := range cases {
+		req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+		assert.Nil(t, err)
+		req.Header.Set("Accept", tc.accept)
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		bodyBytes, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Nil(t, err)
+
+		assert.Equal(t, tc.statusCode, resp.StatusCode, tc.accept)
+		assert.Equal(t, tc.contentType, resp.Header.Get("Content-Type"), tc.accept)
+		assert.Equal(t, tc.body, string(bodyBytes), tc.accept)
+		assert.Equal(t, "Accept", resp.Header.Get("Vary"), tc.accept)
+	}
+}
+
+func TestAddVaryHeader(t *testing.T) {
+	header := http.Header{}
+	header.Add("Vary", "Origin, accept")
+	addVaryHeader(header, "Accept")
+	addVaryHeader(header, "Accept-Encoding")
+	addVaryHeader(header, "Accept-Encoding")
+	assert.Equal(t, []string{"Origin, accept", "Accept-Encoding"}, header.Values("Vary"))
+}
@@ -0,0 +1,638 @@
+package looli
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	MIMEMergePatchJSON = "application/merge-patch+json"
+	MIMEJSONPatch      = "application/json-patch+json"
+)
+
+// BindPatch applies the patch document in the request body to data, which should hold the
+// current value of the resource, and returns the JSON Pointers of the touched fields.
+// Depending the "Content-Type" header different patch formats are used:
+//
+//	"application/merge-patch+json", "application/json" --> JSON Merge Patch (RFC 7396)
+//	"application/json-patch+json" --> JSON Patch (RFC 6902)
+//
+// otherwise, or if the header is absent, an *Error with Code http.StatusUnsupportedMediaType
+// is returned. A malformed patch document returns an *Error with Code http.StatusBadRequest,
+// an operation which can not be applied returns an *Error with Code
+// http.StatusUnprocessableEntity, in both case data is left unchanged. data is validated
+// after the patch is applied.
+func (c *Context) BindPatch(data BindingStruct) ([]string, error) {
+	contentType := c.ContentType()
+	if contentType == "" {
+		return nil, &Error{
+			Err:  ErrUnsupportedMediaType,
+			Code: http.StatusUnsupportedMediaType,
+		}
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, &Error{
+			Err:  err,
+			Code: http.StatusBadRequest,
+		}
+	}
+
+	var apply func(interface{}, []byte) ([]string, error)
+	switch mediaType {
+	case MIMEMergePatchJSON, MIMEJSON:
+		apply = ApplyMergePatch
+	case MIMEJSONPatch:
+		apply = ApplyJSONPatch
+	default:
+		return nil, &Error{
+			Err:  ErrUnsupportedMediaType,
+			Code: http.StatusUnsupportedMediaType,
+			Meta: mediaType,
+		}
+	}
+
+	patch, err := c.BodyBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	paths, err := apply(data, patch)
+	if err != nil {
+		return nil, err
+	}
+	return paths, data.Validate()
+}
+
+// ApplyMergePatch applies the JSON Merge Patch (RFC 7396) to the value pointed to by ptr, and
+// returns the JSON Pointers of the fields set or removed by the patch, sorted. Fields which are
+// not encoded to JSON, such as unexported fields and fields tagged with "-", keep their values.
+func ApplyMergePatch(ptr interface{}, patch []byte) ([]string, error) {
+	patchDoc, err := decodeJSONDocument(patch)
+	if err != nil {
+		return nil, patchError(http.StatusBadRequest, "invalid merge patch: %v", err)
+	}
+
+	doc, err := encodeJSONDocument(ptr)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := mergePatchPaths("", patchDoc, nil)
+	sort.Strings(paths)
+	if err := decodePatchedDocument(ptr, mergePatch(doc, patchDoc)); err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+// ApplyJSONPatch applies the JSON Patch (RFC 6902) to the value pointed to by ptr, and returns
+// the JSON Pointers of the fields touched by the operations. Operations are applied in order,
+// if any of them fails the value is left unchanged. Fields which are not encoded to JSON, such
+// as unexported fields and fields tagged with "-", keep their values.
+func ApplyJSONPatch(ptr interface{}, patch []byte) ([]string, error) {
+	var operations []map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &operations); err != nil {
+		return nil, patchError(http.StatusBadRequest, "invalid json patch: %v", err)
+	}
+
+	doc, err := encodeJSONDocument(ptr)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	touched := make(map[string]bool)
+	touch := func(path string) {
+		if !touched[path] {
+			touched[path] = true
+			paths = append(paths, path)
+		}
+	}
+
+	for i, operation := range operations {
+		op, err := operationString(operation, "op")
+		if err != nil {
+			return nil, patchError(http.StatusBadRequest, "operation %d: %v", i, err)
+		}
+		path, err := operationString(operation, "path")
+		if err != nil {
+			return nil, patchError(http.StatusBadRequest, "operation %d: %v", i, err)
+		}
+		tokens, err := parseJSONPointer(path)
+		if err != nil {
+			return nil, patchError(http.StatusUnprocessableEntity, "operation %d: %v", i, err)
+		}
+
+		switch op {
+		case "add", "replace", "test":
+			raw, ok := operation["value"]
+			if !ok {
+				return nil, patchError(http.StatusBadRequest, "operation %d: missing value", i)
+			}
+			var value interface{}
+			if value, err = decodeJSONDocument(raw); err != nil {
+				return nil, patchError(http.StatusBadRequest, "operation %d: %v", i, err)
+			}
+
+			switch op {
+			case "add":
+				doc, err = jsonPointerAdd(doc, tokens, value)
+			case "replace":
+				doc, err = jsonPointerReplace(doc, tokens, value)
+			case "test":
+				var current interface{}
+				if current, err = jsonPointerGet(doc, tokens); err == nil && !jsonEqual(current, value) {
+					err = fmt.Errorf("test failed at %q", path)
+				}
+			}
+		case "remove":
+			doc, _, err = jsonPointerRemove(doc, tokens)
+		case "move", "copy":
+			var from string
+			if from, err = operationString(operation, "from"); err != nil {
+				return nil, patchError(http.StatusBadRequest, "operation %d: %v", i, err)
+			}
+			var fromTokens []string
+			if fromTokens, err = parseJSONPointer(from); err != nil {
+				break
+			}
+
+			var value interface{}
+			if op == "move" {
+				if strings.HasPrefix(path, from+"/") {
+					err = fmt.Errorf("can not move %q into its child %q", from, path)
+					break
+				}
+				if doc, value, err = jsonPointerRemove(doc, fromTokens); err == nil {
+					touch(from)
+				}
+			} else if value, err = jsonPointerGet(doc, fromTokens); err == nil {
+				value = copyJSONDocument(value)
+			}
+			if err == nil {
+				doc, err = jsonPointerAdd(doc, tokens, value)
+			}
+		default:
+			return nil, patchError(http.StatusUnprocessableEntity, "operation %d: unknown op %q", i, op)
+		}
+
+		if err != nil {
+			return nil, patchError(http.StatusUnprocessableEntity, "operation %d: %v", i, err)
+		}
+		if op != "test" {
+			touch(path)
+		}
+	}
+
+	if err := decodePatchedDocument(ptr, doc); err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+func patchError(code int, format string, values ...interface{}) *Error {
+	return &Error{
+		Err:  fmt.Errorf(format, values...),
+		Code: code,
+	}
+}
+
+func operationString(operation map[string]json.RawMessage, key string) (string, error) {
+	raw, ok := operation[key]
+	if !ok {
+		return "", fmt.Errorf("missing %s", key)
+	}
+
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", fmt.Errorf("invalid %s: %v", key, err)
+	}
+	return value, nil
+}
+
+// decodeJSONDocument decodes data into generic JSON values, numbers are kept as json.Number
+// so they survive the round trip without losing precision.
+func decodeJSONDocument(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, ErrTrailingData
+	}
+	return doc, nil
+}
+
+func encodeJSONDocument(ptr interface{}) (interface{}, error) {
+	data, err := json.Marshal(ptr)
+	if err != nil {
+		return nil, err
+	}
+	return decodeJSONDocument(data)
+}
+
+// decodePatchedDocument decodes doc into a new value and stores it into ptr, the fields JSON
+// doesn't carry keep the values of the current one.
+func decodePatchedDocument(ptr interface{}, doc interface{}) error {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	val := reflect.ValueOf(ptr)
+	if val.Kind() != reflect.Ptr || val.IsNil() {
+		return fmt.Errorf("patch target must be a non-nil pointer, got %v", val.Type())
+	}
+
+	decoded := reflect.New(val.Type().Elem())
+	if err := json.Unmarshal(data, decoded.Interface()); err != nil {
+		return patchError(http.StatusUnprocessableEntity, "invalid patched document: %v", err)
+	}
+
+	patched := reflect.New(val.Type().Elem())
+	patched.Elem().Set(val.Elem())
+	setJSONFields(patched.Elem(), decoded.Elem(), doc, false)
+	val.Elem().Set(patched.Elem())
+	return nil
+}
+
+var (
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// setJSONFields sets the parts of dst which are decoded from JSON to the ones of decoded, doc
+// is the part of the JSON document decoded. Structs are set field by field, skipping unexported
+// fields and fields tagged with "-", and the structs pointed to are copied before, so the current
+// value is never changed. For structs with their own MarshalJSON, and when partial is true, the
+// fields whose key is absent in doc are skipped too, as they may not be encoded at all.
+func setJSONFields(dst, decoded reflect.Value, doc interface{}, partial bool) {
+	t := dst.Type()
+	if !implements(t, jsonUnmarshalerType) && !implements(t, textUnmarshalerType) {
+		switch {
+		case t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct && !dst.IsNil() && !decoded.IsNil():
+			copied := reflect.New(t.Elem())
+			copied.Elem().Set(dst.Elem())
+			setJSONFields(copied.Elem(), decoded.Elem(), doc, partial)
+			decoded = copied
+		case t.Kind() == reflect.Struct:
+			partial = partial || implements(t, jsonMarshalerType)
+			object, _ := doc.(map[string]interface{})
+			for i := 0; i < t.NumField(); i++ {
+				field := t.Field(i)
+				if field.Tag.Get("json") == "-" || (field.PkgPath != "" && !field.Anonymous) {
+					continue
+				}
+
+				name := jsonFieldName(field)
+				if name == "" {
+					// fields of untagged embedded structs are in the same object
+					setJSONFields(dst.Field(i), decoded.Field(i), doc, partial)
+					continue
+				}
+				value, ok := lookupJSONKey(object, name)
+				if partial && !ok {
+					continue
+				}
+				setJSONFields(dst.Field(i), decoded.Field(i), value, false)
+			}
+			return
+		}
+	}
+
+	// exported fields of unexported embedded structs are settable, but not the structs
+	if dst.CanSet() {
+		dst.Set(decoded)
+	}
+}
+
+// jsonFieldName returns the key of field in JSON objects, or "" for untagged embedded structs
+// whose fields are promoted.
+func jsonFieldName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name != "" {
+		return name
+	}
+
+	typ := field.Type
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if field.Anonymous && typ.Kind() == reflect.Struct {
+		return ""
+	}
+	return field.Name
+}
+
+// lookupJSONKey returns the value of key in object, matching keys case-insensitively like
+// encoding/json does when the exact key is absent.
+func lookupJSONKey(object map[string]interface{}, key string) (interface{}, bool) {
+	if value, ok := object[key]; ok {
+		return value, true
+	}
+	for k, value := range object {
+		if strings.EqualFold(k, key) {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// implements reports whether t or *t implements u.
+func implements(t, u reflect.Type) bool {
+	return t.Implements(u) || reflect.PointerTo(t).Implements(u)
+}
+
+func mergePatch(doc, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	docObject, ok := doc.(map[string]interface{})
+	if !ok {
+		docObject = make(map[string]interface{})
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(docObject, key)
+		} else {
+			docObject[key] = mergePatch(docObject[key], value)
+		}
+	}
+	return docObject
+}
+
+// mergePatchPaths return the JSON Pointers of the leaves in the merge patch.
+func mergePatchPaths(prefix string, patch interface{}, paths []string) []string {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok || (len(patchObject) == 0 && prefix != "") {
+		return append(paths, prefix)
+	}
+
+	for key, value := range patchObject {
+		paths = mergePatchPaths(prefix+"/"+escapeJSONPointer(key), value, paths)
+	}
+	return paths
+}
+
+// parseJSONPointer parses JSON Pointer (RFC 6901) into reference tokens.
+func parseJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("invalid json pointer %q", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+	}
+	return tokens, nil
+}
+
+func escapeJSONPointer(token string) string {
+	return strings.Replace(strings.Replace(token, "~", "~0", -1), "/", "~1", -1)
+}
+
+// arrayIndex parses token as index of array with length, "-" is accepted when appendable.
+func arrayIndex(token string, length int, appendable bool) (int, error) {
+	if token == "-" && appendable {
+		return length, nil
+	}
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+
+	max := length - 1
+	if appendable {
+		max = length
+	}
+	if index > max {
+		return 0, fmt.Errorf("array index %d out of range", index)
+	}
+	return index, nil
+}
+
+func jsonPointerGet(doc interface{}, tokens []string) (interface{}, error) {
+	for _, token := range tokens {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("path %q not found", token)
+			}
+			doc = value
+		case []interface{}:
+			index, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[index]
+		default:
+			return nil, fmt.Errorf("path %q not found", token)
+		}
+	}
+	return doc, nil
+}
+
+// jsonPointerUpdate calls update with the container which holds the last token, and stores
+// the returned container back into doc. It returns the updated doc.
+func jsonPointerUpdate(doc interface{}, tokens []string, update func(interface{}, string) (interface{}, error)) (interface{}, error) {
+	if len(tokens) == 1 {
+		return update(doc, tokens[0])
+	}
+
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		child, ok := node[tokens[0]]
+		if !ok {
+			return nil, fmt.Errorf("path %q not found", tokens[0])
+		}
+		child, err := jsonPointerUpdate(child, tokens[1:], update)
+		if err != nil {
+			return nil, err
+		}
+		node[tokens[0]] = child
+		return node, nil
+	case []interface{}:
+		index, err := arrayIndex(tokens[0], len(node), false)
+		if err != nil {
+			return nil, err
+		}
+		child, err := jsonPointerUpdate(node[index], tokens[1:], update)
+		if err != nil {
+			return nil, err
+		}
+		node[index] = child
+		return node, nil
+	default:
+		return nil, fmt.Errorf("path %q not found", tokens[0])
+	}
+}
+
+func jsonPointerAdd(doc interface{}, tokens []string, value interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+
+	return jsonPointerUpdate(doc, tokens, func(parent interface{}, token string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			node[token] = value
+			return node, nil
+		case []interface{}:
+			index, err := arrayIndex(token, len(node), true)
+			if err != nil {
+				return nil, err
+			}
+			node = append(node, nil)
+			copy(node[index+1:], node[index:])
+			node[index] = value
+			return node, nil
+		default:
+			return nil, fmt.Errorf("path %q not found", token)
+		}
+	})
+}
+
+func jsonPointerReplace(doc interface{}, tokens []string, value interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+
+	return jsonPointerUpdate(doc, tokens, func(parent interface{}, token string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			if _, ok := node[token]; !ok {
+				return nil, fmt.Errorf("path %q not found", token)
+			}
+			node[token] = value
+			return node, nil
+		case []interface{}:
+			index, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			node[index] = value
+			return node, nil
+		default:
+			return nil, fmt.Errorf("path %q not found", token)
+		}
+	})
+}
+
+// jsonPointerRemove removes the value at tokens, and returns the updated doc and the removed value.
+func jsonPointerRemove(doc interface{}, tokens []string) (interface{}, interface{}, error) {
+	if len(tokens) == 0 {
+		return nil, nil, fmt.Errorf("can not remove the whole document")
+	}
+
+	var removed interface{}
+	doc, err := jsonPointerUpdate(doc, tokens, func(parent interface{}, token string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("path %q not found", token)
+			}
+			removed = value
+			delete(node, token)
+			return node, nil
+		case []interface{}:
+			index, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			removed = node[index]
+			return append(node[:index], node[index+1:]...), nil
+		default:
+			return nil, fmt.Errorf("path %q not found", token)
+		}
+	})
+	return doc, removed, err
+}
+
+func copyJSONDocument(doc interface{}) interface{} {
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(node))
+		for key, value := range node {
+			object[key] = copyJSONDocument(value)
+		}
+		return object
+	case []interface{}:
+		array := make([]interface{}, len(node))
+		for i, value := range node {
+			array[i] = copyJSONDocument(value)
+		}
+		return array
+	default:
+		return doc
+	}
+}
+
+// jsonEqual compares two JSON documents as the test operation requires, numbers are
+// equal if their values are numerically equal.
+func jsonEqual(a, b interface{}) bool {
+	switch x := a.(type) {
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for key, value := range x {
+			other, ok := y[key]
+			if !ok || !jsonEqual(value, other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !jsonEqual(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		if x == y {
+			return true
+		}
+		xf, errx := x.Float64()
+		yf, erry := y.Float64()
+		return errx == nil && erry == nil && xf == yf
+	default:
+		return a == b
+	}
+}
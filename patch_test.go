@@ -0,0 +1,247 @@
+package looli
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type Article struct {
+	Title  string            `json:"title"`
+	Author *Author           `json:"author,omitempty"`
+	Tags   []string          `json:"tags"`
+	Meta   map[string]string `json:"meta,omitempty"`
+	Views  int64             `json:"views"`
+
+	PasswordHash string `json:"-"`
+	revision     int
+}
+
+type Author struct {
+	Name  string `json:"name"`
+	Email string `json:"email,omitempty"`
+	ID    int64  `json:"-"`
+}
+
+func (a *Article) Validate() error {
+	return nil
+}
+
+func newArticle() *Article {
+	return &Article{
+		Title:  "hello",
+		Author: &Author{Name: "cssivision", Email: "a@b.c", ID: 7},
+		Tags:   []string{"go", "web"},
+		Meta:   map[string]string{"lang": "en"},
+		Views:  9007199254740993,
+
+		PasswordHash: "secret",
+		revision:     3,
+	}
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	article := newArticle()
+	paths, err := ApplyMergePatch(article, []byte(`{"title":"world","author":{"email":null},"meta":{"lang":null,"tz":"utc"}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/author/email", "/meta/lang", "/meta/tz", "/title"}, paths)
+	assert.Equal(t, "world", article.Title)
+	assert.Equal(t, &Author{Name: "cssivision", ID: 7}, article.Author)
+	assert.Equal(t, map[string]string{"tz": "utc"}, article.Meta)
+	assert.Equal(t, []string{"go", "web"}, article.Tags)
+	assert.Equal(t, int64(9007199254740993), article.Views)
+	// fields which are not encoded to JSON are kept
+	assert.Equal(t, "secret", article.PasswordHash)
+	assert.Equal(t, 3, article.revision)
+
+	// the author is replaced by a copy, the original one is not changed
+	article = newArticle()
+	author := article.Author
+	_, err = ApplyMergePatch(article, []byte(`{"author":{"name":"looli"}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, &Author{Name: "looli", Email: "a@b.c", ID: 7}, article.Author)
+	assert.Equal(t, "cssivision", author.Name)
+
+	_, err = ApplyMergePatch(article, []byte(`{"author":null,"meta":null}`))
+	assert.Nil(t, err)
+	assert.Nil(t, article.Author)
+	assert.Nil(t, article.Meta)
+
+	article = newArticle()
+	_, err = ApplyMergePatch(article, []byte(`{"title":`))
+	assert.Equal(t, http.StatusBadRequest, err.(*Error).Code)
+
+	_, err = ApplyMergePatch(article, []byte(`{"title":1}`))
+	assert.Equal(t, http.StatusUnprocessableEntity, err.(*Error).Code)
+	assert.Equal(t, newArticle(), article)
+}
+
+type patchModel struct {
+	Name string `json:"name"`
+}
+
+type patchUser struct {
+	patchModel
+	Email   string `json:"email"`
+	Profile patchProfile
+	token   string
+}
+
+type patchProfile struct {
+	Bio      string `json:"bio"`
+	Verified bool   `json:"verified"`
+}
+
+// MarshalJSON hides Verified
+func (p patchProfile) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{"bio": p.Bio})
+}
+
+func TestApplyPatchHiddenFields(t *testing.T) {
+	newUser := func() *patchUser {
+		return &patchUser{
+			patchModel: patchModel{Name: "looli"},
+			Email:      "a@b.c",
+			Profile:    patchProfile{Bio: "hi", Verified: true},
+			token:      "token",
+		}
+	}
+
+	user := newUser()
+	paths, err := ApplyMergePatch(user, []byte(`{"name":"cssivision","email":null,"Profile":{"bio":"hello"}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/Profile/bio", "/email", "/name"}, paths)
+	assert.Equal(t, &patchUser{
+		patchModel: patchModel{Name: "cssivision"},
+		Profile:    patchProfile{Bio: "hello", Verified: true},
+		token:      "token",
+	}, user)
+
+	user = newUser()
+	_, err = ApplyJSONPatch(user, []byte(`[{"op":"replace","path":"/email","value":"d@e.f"}]`))
+	assert.Nil(t, err)
+	assert.Equal(t, "d@e.f", user.Email)
+	assert.Equal(t, "token", user.token)
+	assert.True(t, user.Profile.Verified)
+
+	// fields of MarshalJSON types present in the patch are set, even to zero values
+	user = newUser()
+	paths, err = ApplyMergePatch(user, []byte(`{"Profile":{"bio":"","verified":false}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/Profile/bio", "/Profile/verified"}, paths)
+	assert.Equal(t, patchProfile{}, user.Profile)
+
+	user = newUser()
+	_, err = ApplyJSONPatch(user, []byte(`[{"op":"replace","path":"/Profile/bio","value":""}]`))
+	assert.Nil(t, err)
+	assert.Equal(t, patchProfile{Verified: true}, user.Profile)
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	t.Run("operations", func(t *testing.T) {
+		article := newArticle()
+		paths, err := ApplyJSONPatch(article, []byte(`[
+			{"op": "test", "path": "/title", "value": "hello"},
+			{"op": "replace", "path": "/title", "value": "world"},
+			{"op": "add", "path": "/tags/1", "value": "json"},
+			{"op": "add", "path": "/tags/-", "value": "patch"},
+			{"op": "remove", "path": "/tags/0"},
+			{"op": "copy", "from": "/author/name", "path": "/meta/author"},
+			{"op": "move", "from": "/meta/lang", "path": "/meta/locale"},
+			{"op": "remove", "path": "/author/email"},
+			{"op": "test", "path": "/views", "value": 9007199254740993}
+		]`))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"/title", "/tags/1", "/tags/-", "/tags/0", "/meta/author", "/meta/lang", "/meta/locale", "/author/email"}, paths)
+		assert.Equal(t, "world", article.Title)
+		assert.Equal(t, []string{"json", "web", "patch"}, article.Tags)
+		assert.Equal(t, map[string]string{"author": "cssivision", "locale": "en"}, article.Meta)
+		assert.Equal(t, &Author{Name: "cssivision", ID: 7}, article.Author)
+		assert.Equal(t, "secret", article.PasswordHash)
+		assert.Equal(t, 3, article.revision)
+	})
+
+	t.Run("escaped pointer", func(t *testing.T) {
+		article := newArticle()
+		_, err := ApplyJSONPatch(article, []byte(`[{"op": "add", "path": "/meta/a~1b~0c", "value": "d"}]`))
+		assert.Nil(t, err)
+		assert.Equal(t, "d", article.Meta["a/b~c"])
+	})
+
+	t.Run("invalid operations", func(t *testing.T) {
+		patches := map[string]int{
+			`{"op": "add"}`:                                            http.StatusBadRequest,
+			`[{"path": "/title", "value": "a"}]`:                       http.StatusBadRequest,
+			`[{"op": "add", "path": "/title"}]`:                        http.StatusBadRequest,
+			`[{"op": "move", "path": "/title"}]`:                       http.StatusBadRequest,
+			`[{"op": "unknown", "path": "/title"}]`:                    http.StatusUnprocessableEntity,
+			`[{"op": "test", "path": "/title", "value": "world"}]`:     http.StatusUnprocessableEntity,
+			`[{"op": "replace", "path": "/missing", "value": "a"}]`:    http.StatusUnprocessableEntity,
+			`[{"op": "remove", "path": "/tags/2"}]`:                    http.StatusUnprocessableEntity,
+			`[{"op": "add", "path": "/tags/01", "value": "a"}]`:        http.StatusUnprocessableEntity,
+			`[{"op": "add", "path": "title", "value": "a"}]`:           http.StatusUnprocessableEntity,
+			`[{"op": "move", "from": "/author", "path": "/author/a"}]`: http.StatusUnprocessableEntity,
+			`[{"op": "replace", "path": "/views", "value": "a"}]`:      http.StatusUnprocessableEntity,
+		}
+
+		for patch, code := range patches {
+			article := newArticle()
+			_, err := ApplyJSONPatch(article, []byte(`[{"op": "replace", "path": "/title", "value": "world"}]`))
+			assert.Nil(t, err)
+
+			article = newArticle()
+			_, err = ApplyJSONPatch(article, []byte(patch))
+			if assert.NotNil(t, err, patch) {
+				assert.Equal(t, code, err.(*Error).Code, patch)
+			}
+			assert.Equal(t, newArticle(), article, patch)
+		}
+	})
+}
+
+func TestBindPatch(t *testing.T) {
+	patchStatus := func(t *testing.T, contentType, body string) (int, *Article) {
+		article := newArticle()
+		router := New()
+		router.Patch("/article", func(c *Context) {
+			if _, err := c.BindPatch(article); err != nil {
+				c.Status(err.(*Error).Code)
+			}
+		})
+
+		server := httptest.NewServer(router)
+		defer server.Close()
+
+		req, err := http.NewRequest(http.MethodPatch, server.URL+"/article", strings.NewReader(body))
+		assert.Nil(t, err)
+		req.Header.Set("Content-Type", contentType)
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		return resp.StatusCode, article
+	}
+
+	status, article := patchStatus(t, MIMEMergePatchJSON, `{"title":"world"}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "world", article.Title)
+
+	status, article = patchStatus(t, MIMEJSONPatch+"; charset=utf-8", `[{"op":"remove","path":"/tags"}]`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Nil(t, article.Tags)
+
+	status, _ = patchStatus(t, MIMEXML, `<title>world</title>`)
+	assert.Equal(t, http.StatusUnsupportedMediaType, status)
+
+	status, _ = patchStatus(t, "", `{"title":"world"}`)
+	assert.Equal(t, http.StatusUnsupportedMediaType, status)
+
+	status, _ = patchStatus(t, "application/", `{"title":"world"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = patchStatus(t, MIMEJSONPatch, `[{"op":"remove","path":"/missing"}]`)
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+}
@@ -7,7 +7,7 @@ import (
 	"runtime"
 )
 
-var defaultErrorWriter = os.Stderr
+var defaultErrorWriter io.Writer = os.Stderr
 
 func Recover() HandlerFunc {
 	return RecoverWithWriter(defaultErrorWriter)
@@ -0,0 +1,260 @@
+package looli
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// templateLocation matches the template name and line in the errors of html/template, such
+// as "template: users/show.tmpl:3: function "foo" not defined" and
+// "html/template:users/show.tmpl:3:5: no such template".
+var templateLocation = regexp.MustCompile(`template: ?([^:\s]+):(\d+):`)
+
+// templateErrorPage is rendered in debug mode when templates fail to parse or execute.
+var templateErrorPage = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Template error</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #333; }
+h1 { color: #c00; font-size: 1.4em; }
+pre { background: #f6f6f6; padding: 1em; overflow: auto; }
+.line { display: block; }
+.error { background: #fdd; }
+</style>
+</head>
+<body>
+<h1>Template error</h1>
+<p>Rendering <code>{{ .Name }}</code> failed:</p>
+<pre>{{ .Err }}</pre>
+{{ if .Source }}<p><code>{{ .File }}</code></p>
+<pre>{{ range .Source }}<span class="line{{ if .Error }} error{{ end }}">{{ printf "%4d" .Number }}  {{ .Text }}</span>{{ end }}</pre>{{ end }}
+<p>The templates are reloaded when the files change.</p>
+</body>
+</html>
+`))
+
+// templateRenderer renders templates of a *template.Template.
+type templateRenderer struct {
+	template *template.Template
+}
+
+func (r templateRenderer) Instance(name string, data interface{}) Render {
+	return HTMLRender{Template: r.template, Name: name, Data: data}
+}
+
+// fileStamp is used to detect the modification of a file.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// reloadRenderer parses the templates again before rendering if the files have changed, it
+// is used in debug mode. Templates are executed into a buffer, errors of parsing and
+// executing are rendered as an error page with status 500.
+type reloadRenderer struct {
+	// file system of the templates, files are read from the OS file system if it is nil
+	fsys fs.FS
+
+	// files returns the template files to watch
+	files func() ([]string, error)
+
+	// parse parses the template files
+	parse func(files []string) (HTMLRenderer, error)
+
+	mu       sync.Mutex
+	renderer HTMLRenderer
+	err      error
+	stamps   map[string]fileStamp
+}
+
+// loadHTMLReloader sets a reloadRenderer with files in fsys and parse as HTMLRenderer.
+func (engine *Engine) loadHTMLReloader(fsys fs.FS, files func() ([]string, error), parse func([]string) (HTMLRenderer, error)) {
+	engine.Template = nil
+	engine.HTMLRenderer = newReloadRenderer(fsys, files, parse)
+}
+
+// newReloadRenderer returns a reloadRenderer parsing files in fsys with parse, errors are
+// rendered instead of panicking.
+func newReloadRenderer(fsys fs.FS, files func() ([]string, error), parse func([]string) (HTMLRenderer, error)) *reloadRenderer {
+	r := &reloadRenderer{fsys: fsys, files: files, parse: parse}
+	r.reload()
+	return r
+}
+
+// reload parses the templates and records the stamps of files.
+func (r *reloadRenderer) reload() {
+	files, err := r.files()
+	r.stamps = r.statFiles(files)
+	if err != nil {
+		r.renderer, r.err = nil, err
+		return
+	}
+
+	renderer, err := r.parse(files)
+	if err != nil {
+		renderer = nil
+	}
+	r.renderer, r.err = renderer, err
+}
+
+// changed reports whether a file is added, removed or modified since last reload.
+func (r *reloadRenderer) changed() bool {
+	files, _ := r.files()
+	stamps := r.statFiles(files)
+	if len(stamps) != len(r.stamps) {
+		return true
+	}
+	for file, stamp := range stamps {
+		if old, ok := r.stamps[file]; !ok || !old.modTime.Equal(stamp.modTime) || old.size != stamp.size {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *reloadRenderer) statFiles(files []string) map[string]fileStamp {
+	stamps := make(map[string]fileStamp, len(files))
+	for _, file := range files {
+		if info, err := r.stat(file); err == nil {
+			stamps[file] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+		}
+	}
+	return stamps
+}
+
+func (r *reloadRenderer) stat(file string) (fs.FileInfo, error) {
+	if r.fsys != nil {
+		return fs.Stat(r.fsys, file)
+	}
+	return os.Stat(file)
+}
+
+func (r *reloadRenderer) readFile(file string) ([]byte, error) {
+	if r.fsys != nil {
+		return fs.ReadFile(r.fsys, file)
+	}
+	return os.ReadFile(file)
+}
+
+func (r *reloadRenderer) Instance(name string, data interface{}) Render {
+	r.mu.Lock()
+	if r.changed() {
+		r.reload()
+	}
+	renderer, err := r.renderer, r.err
+	r.mu.Unlock()
+
+	if err != nil {
+		return r.errorPage(name, err)
+	}
+
+	w := &bufferResponseWriter{header: make(http.Header)}
+	if err := renderer.Instance(name, data).Render(w); err != nil {
+		return r.errorPage(name, err)
+	}
+	return htmlBytesRender(w.buf.Bytes())
+}
+
+// sourceLine is a line of template shown in the error page.
+type sourceLine struct {
+	Number int
+	Text   string
+	Error  bool
+}
+
+// errorPage returns the error page of err, the source around the line of error is shown if
+// the template file is found.
+func (r *reloadRenderer) errorPage(name string, err error) Render {
+	page := struct {
+		Name   string
+		Err    error
+		File   string
+		Source []sourceLine
+	}{Name: name, Err: err}
+
+	if match := templateLocation.FindStringSubmatch(err.Error()); match != nil {
+		line, _ := strconv.Atoi(match[2])
+		files, _ := r.files()
+		for _, file := range files {
+			slashed := filepath.ToSlash(file)
+			if slashed != match[1] && !strings.HasSuffix(slashed, "/"+match[1]) {
+				continue
+			}
+
+			content, readErr := r.readFile(file)
+			if readErr != nil {
+				break
+			}
+			lines := strings.Split(string(content), "\n")
+			for i := line - 3; i <= line+3; i++ {
+				if i >= 1 && i <= len(lines) {
+					page.Source = append(page.Source, sourceLine{Number: i, Text: lines[i-1], Error: i == line})
+				}
+			}
+			page.File = file
+			break
+		}
+	}
+
+	var buf bytes.Buffer
+	if execErr := templateErrorPage.Execute(&buf, page); execErr != nil {
+		fmt.Fprintf(&buf, "%s: %s", name, template.HTMLEscapeString(err.Error()))
+	}
+	return templateErrorRender(buf.Bytes())
+}
+
+// htmlBytesRender writes the HTML rendered in advance.
+type htmlBytesRender []byte
+
+func (r htmlBytesRender) Render(rw http.ResponseWriter) error {
+	_, err := rw.Write(r)
+	return err
+}
+
+func (r htmlBytesRender) WriteContentType(rw http.ResponseWriter) {
+	setContentType(rw, htmlContentType)
+}
+
+// templateErrorRender writes the error page of templates with status 500.
+type templateErrorRender []byte
+
+func (r templateErrorRender) statusCode() int {
+	return http.StatusInternalServerError
+}
+
+func (r templateErrorRender) Render(rw http.ResponseWriter) error {
+	_, err := rw.Write(r)
+	return err
+}
+
+func (r templateErrorRender) WriteContentType(rw http.ResponseWriter) {
+	setContentType(rw, htmlContentType)
+}
+
+// bufferResponseWriter buffers the body written by a Render.
+type bufferResponseWriter struct {
+	header http.Header
+	buf    bytes.Buffer
+}
+
+func (w *bufferResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferResponseWriter) Write(data []byte) (int, error) {
+	return w.buf.Write(data)
+}
+
+func (w *bufferResponseWriter) WriteHeader(int) {}
@@ -0,0 +1,99 @@
+package looli
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplateReload(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "index.tmpl")
+	modTime := time.Now().Add(-time.Hour)
+	writeTemplate := func(content string) {
+		assert.Nil(t, os.WriteFile(file, []byte(content), 0644))
+		modTime = modTime.Add(time.Second)
+		assert.Nil(t, os.Chtimes(file, modTime, modTime))
+	}
+
+	writeTemplate("<h1>{{ .title }}</h1>")
+
+	router := New()
+	router.Debug = true
+	router.LoadHTMLGlob(filepath.Join(dir, "*.tmpl"))
+	router.Get("/", func(c *Context) {
+		c.HTML(http.StatusOK, "index.tmpl", JSON{"title": "Posts"})
+	})
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	get := func() (int, string) {
+		resp, err := http.Get(server.URL)
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		bodyBytes, err := ioutil.ReadAll(resp.Body)
+		assert.Nil(t, err)
+		assert.Equal(t, htmlContentType[0], resp.Header.Get("Content-Type"))
+		return resp.StatusCode, string(bodyBytes)
+	}
+
+	statusCode, body := get()
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.Equal(t, "<h1>Posts</h1>", body)
+
+	writeTemplate("<h2>{{ .title }}</h2>")
+	statusCode, body = get()
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.Equal(t, "<h2>Posts</h2>", body)
+
+	writeTemplate("<h2>\n{{ .title }\n</h2>")
+	statusCode, body = get()
+	assert.Equal(t, http.StatusInternalServerError, statusCode)
+	assert.Contains(t, body, "Template error")
+	assert.Contains(t, body, "index.tmpl:2")
+	assert.Contains(t, body, `<span class="line error">   2  {{ .title }</span>`)
+
+	writeTemplate("<h2>{{ .title.missing }}</h2>")
+	statusCode, body = get()
+	assert.Equal(t, http.StatusInternalServerError, statusCode)
+	assert.Contains(t, body, "Template error")
+
+	writeTemplate("<h3>{{ .title }}</h3>")
+	statusCode, body = get()
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.Equal(t, "<h3>Posts</h3>", body)
+}
+
+func TestTemplateReloadLayout(t *testing.T) {
+	router := New()
+	router.Debug = true
+	router.LoadHTMLTemplates(TemplateOptions{
+		Dir:    "test/views",
+		Layout: "layouts/base.tmpl",
+		Pages:  []string{"*.tmpl"},
+	})
+	router.Get("/", func(c *Context) {
+		c.HTML(http.StatusOK, "index.tmpl", JSON{"title": "Posts"})
+	})
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	// the layout calls the partial which is not loaded
+	resp, err := http.Get(server.URL)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Contains(t, string(bodyBytes), "partials/nav.tmpl")
+	assert.Contains(t, string(bodyBytes), "<code>layouts/base.tmpl</code>")
+	assert.Contains(t, string(bodyBytes), `<span class="line error">   1  &lt;html&gt;`)
+}
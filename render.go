@@ -1,17 +1,94 @@
 package looli
 
 import (
+	"bytes"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"fmt"
 	"html/template"
 	"io"
 	"net/http"
+	"regexp"
+	"unicode/utf8"
 )
 
 var (
-	plainContentType = []string{"text/plain; charset=utf-8"}
-	jsonContentType  = []string{"application/json; charset=utf-8"}
-	htmlContentType  = []string{"text/html; charset=utf-8"}
+	plainContentType      = []string{"text/plain; charset=utf-8"}
+	jsonContentType       = []string{"application/json; charset=utf-8"}
+	htmlContentType       = []string{"text/html; charset=utf-8"}
+	xmlContentType        = []string{"application/xml; charset=utf-8"}
+	javascriptContentType = []string{"application/javascript; charset=utf-8"}
+	asciiJSONContentType  = []string{"application/json"}
+)
+
+// defaultSecureJSONPrefix is prepended to the response of SecureJSONRender when no prefix is set.
+const defaultSecureJSONPrefix = "while(1);"
+
+// ErrInvalidCallback is returned by JSONPRender when the callback is not a valid javascript identifier.
+var ErrInvalidCallback = errors.New("invalid jsonp callback")
+
+// callbackPattern matches dotted javascript identifiers such as "callback" or "jQuery.fn_1".
+var callbackPattern = regexp.MustCompile(`^[a-zA-Z_$][a-zA-Z0-9_$]*(\.[a-zA-Z_$][a-zA-Z0-9_$]*)*$`)
+
+// Render is implemented by the types which can write a response body, use Context.Render to
+// write a Render with status code.
+type Render interface {
+	// Render writes the response body.
+	Render(http.ResponseWriter) error
+
+	// WriteContentType writes the Content-Type header, it is called before the status code is written.
+	WriteContentType(http.ResponseWriter)
+}
+
+type (
+	// StringRender renders Format with Data as plain text.
+	StringRender struct {
+		Format string
+		Data   []interface{}
+	}
+
+	// JSONRender renders Data as JSON.
+	JSONRender struct {
+		Data interface{}
+	}
+
+	// IndentedJSONRender renders Data as indented JSON, it is easier for human to read.
+	IndentedJSONRender struct {
+		Data interface{}
+	}
+
+	// JSONPRender renders Data as JSON wrapped in a call to Callback, if Callback is empty
+	// Data is rendered as JSON.
+	JSONPRender struct {
+		Callback string
+		Data     interface{}
+	}
+
+	// SecureJSONRender renders Data as JSON prefixed with Prefix, default is "while(1);",
+	// to prevent JSON hijacking.
+	SecureJSONRender struct {
+		Prefix string
+		Data   interface{}
+	}
+
+	// ASCIIJSONRender renders Data as JSON with non-ASCII characters escaped to \uXXXX.
+	ASCIIJSONRender struct {
+		Data interface{}
+	}
+
+	// XMLRender renders Data as XML.
+	XMLRender struct {
+		Data interface{}
+	}
+
+	// HTMLRender executes the template Name in Template with Data, if Name is empty
+	// Template itself is executed.
+	HTMLRender struct {
+		Template *template.Template
+		Name     string
+		Data     interface{}
+	}
 )
 
 func setContentType(rw http.ResponseWriter, value []string) {
@@ -43,3 +120,139 @@ func renderHTML(rw http.ResponseWriter, templ *template.Template, name string, d
 
 	return templ.ExecuteTemplate(rw, name, data)
 }
+
+func (r StringRender) Render(rw http.ResponseWriter) error {
+	return renderString(rw, r.Format, r.Data...)
+}
+
+func (r StringRender) WriteContentType(rw http.ResponseWriter) {
+	setContentType(rw, plainContentType)
+}
+
+func (r JSONRender) Render(rw http.ResponseWriter) error {
+	return renderJSON(rw, r.Data)
+}
+
+func (r JSONRender) WriteContentType(rw http.ResponseWriter) {
+	setContentType(rw, jsonContentType)
+}
+
+func (r IndentedJSONRender) Render(rw http.ResponseWriter) error {
+	r.WriteContentType(rw)
+	data, err := json.MarshalIndent(r.Data, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = rw.Write(append(data, '\n'))
+	return err
+}
+
+func (r IndentedJSONRender) WriteContentType(rw http.ResponseWriter) {
+	setContentType(rw, jsonContentType)
+}
+
+func (r JSONPRender) Render(rw http.ResponseWriter) error {
+	if r.Callback == "" {
+		return renderJSON(rw, r.Data)
+	}
+	if !callbackPattern.MatchString(r.Callback) {
+		return ErrInvalidCallback
+	}
+
+	r.WriteContentType(rw)
+	data, err := json.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+
+	// the leading comment prevents the Rosetta Flash attack
+	_, err = fmt.Fprintf(rw, "/**/ typeof %s === 'function' && %s(%s);", r.Callback, r.Callback, data)
+	return err
+}
+
+func (r JSONPRender) WriteContentType(rw http.ResponseWriter) {
+	if r.Callback == "" {
+		setContentType(rw, jsonContentType)
+	} else {
+		setContentType(rw, javascriptContentType)
+	}
+}
+
+func (r SecureJSONRender) Render(rw http.ResponseWriter) error {
+	r.WriteContentType(rw)
+	data, err := json.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+
+	prefix := r.Prefix
+	if prefix == "" {
+		prefix = defaultSecureJSONPrefix
+	}
+	if _, err := io.WriteString(rw, prefix); err != nil {
+		return err
+	}
+	_, err = rw.Write(data)
+	return err
+}
+
+func (r SecureJSONRender) WriteContentType(rw http.ResponseWriter) {
+	setContentType(rw, jsonContentType)
+}
+
+func (r ASCIIJSONRender) Render(rw http.ResponseWriter) error {
+	r.WriteContentType(rw)
+	data, err := json.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+
+	buf := new(bytes.Buffer)
+	for _, char := range string(data) {
+		if char < utf8.RuneSelf {
+			buf.WriteRune(char)
+		} else if char > 0xFFFF {
+			// encode as UTF-16 surrogate pair
+			char -= 0x10000
+			fmt.Fprintf(buf, `\u%04x\u%04x`, 0xD800+(char>>10), 0xDC00+(char&0x3FF))
+		} else {
+			fmt.Fprintf(buf, `\u%04x`, char)
+		}
+	}
+	_, err = rw.Write(buf.Bytes())
+	return err
+}
+
+func (r ASCIIJSONRender) WriteContentType(rw http.ResponseWriter) {
+	setContentType(rw, asciiJSONContentType)
+}
+
+func (r XMLRender) Render(rw http.ResponseWriter) error {
+	r.WriteContentType(rw)
+	return xml.NewEncoder(rw).Encode(r.Data)
+}
+
+func (r XMLRender) WriteContentType(rw http.ResponseWriter) {
+	setContentType(rw, xmlContentType)
+}
+
+func (r HTMLRender) Render(rw http.ResponseWriter) error {
+	return renderHTML(rw, r.Template, r.Name, r.Data)
+}
+
+func (r HTMLRender) WriteContentType(rw http.ResponseWriter) {
+	setContentType(rw, htmlContentType)
+}
+
+// bodyAllowedForStatus reports whether a given response status code permits a body.
+func bodyAllowedForStatus(status int) bool {
+	switch {
+	case status >= 100 && status <= 199:
+		return false
+	case status == http.StatusNoContent:
+		return false
+	case status == http.StatusNotModified:
+		return false
+	}
+	return true
+}
@@ -136,3 +136,104 @@ func TestRenderHTML(t *testing.T) {
 		assert.True(t, strings.Contains(string(bodyBytes), "Posts"))
 	})
 }
+
+func TestRenders(t *testing.T) {
+	data := JSON{
+		"name": "cssivision",
+		"city": "上海",
+	}
+
+	cases := []struct {
+		name        string
+		render      Render
+		contentType string
+		body        string
+	}{
+		{"string", StringRender{Format: "hello %s", Data: []interface{}{"world"}}, plainContentType[0], "hello world"},
+		{"json", JSONRender{Data: data}, jsonContentType[0], `{"city":"上海","name":"cssivision"}` + "\n"},
+		{"indented json", IndentedJSONRender{Data: data}, jsonContentType[0], "{\n    \"city\": \"上海\",\n    \"name\": \"cssivision\"\n}\n"},
+		{"jsonp", JSONPRender{Callback: "jQuery.cb_1", Data: data}, javascriptContentType[0], `/**/ typeof jQuery.cb_1 === 'function' && jQuery.cb_1({"city":"上海","name":"cssivision"});`},
+		{"jsonp without callback", JSONPRender{Data: data}, jsonContentType[0], `{"city":"上海","name":"cssivision"}` + "\n"},
+		{"secure json", SecureJSONRender{Data: []int{1, 2}}, jsonContentType[0], "while(1);[1,2]"},
+		{"secure json with prefix", SecureJSONRender{Prefix: ")]}',\n", Data: []int{1, 2}}, jsonContentType[0], ")]}',\n[1,2]"},
+		{"ascii json", ASCIIJSONRender{Data: JSON{"city": "上海😀"}}, asciiJSONContentType[0], `{"city":"\u4e0a\u6d77\ud83d\ude00"}`},
+		{"xml", XMLRender{Data: Info2{Name: "cssivision", Age: 21}}, xmlContentType[0], "<person><name>cssivision</name><age>21</age><other></other></person>"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			tc.render.WriteContentType(rw)
+			assert.Equal(t, tc.contentType, rw.Header().Get("Content-Type"))
+
+			rw = httptest.NewRecorder()
+			assert.Nil(t, tc.render.Render(rw))
+			assert.Equal(t, tc.contentType, rw.Header().Get("Content-Type"))
+			assert.Equal(t, tc.body, rw.Body.String())
+		})
+	}
+
+	t.Run("invalid callback", func(t *testing.T) {
+		for _, callback := range []string{"alert(1)", "a b", "1a", "a..b", "<script>"} {
+			rw := httptest.NewRecorder()
+			assert.Equal(t, ErrInvalidCallback, JSONPRender{Callback: callback, Data: data}.Render(rw), callback)
+			assert.Empty(t, rw.Body.String())
+		}
+	})
+}
+
+func TestRender(t *testing.T) {
+	router := New()
+	router.SecureJSONPrefix = ")]}'\n"
+	router.Get("/render", func(c *Context) {
+		c.Render(http.StatusCreated, XMLRender{Data: Info2{Name: "cssivision"}})
+	})
+	router.Get("/nocontent", func(c *Context) {
+		c.Render(http.StatusNoContent, JSONRender{Data: JSON{"name": "cssivision"}})
+	})
+	router.Get("/xml", func(c *Context) {
+		c.XML(http.StatusOK, Info2{Name: "cssivision"})
+	})
+	router.Get("/indented", func(c *Context) {
+		c.IndentedJSON(http.StatusOK, []int{1})
+	})
+	router.Get("/jsonp", func(c *Context) {
+		c.JSONP(http.StatusOK, []int{1})
+	})
+	router.Get("/secure", func(c *Context) {
+		c.SecureJSON(http.StatusOK, []int{1})
+	})
+	router.Get("/ascii", func(c *Context) {
+		c.ASCIIJSON(http.StatusOK, "é")
+	})
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	cases := []struct {
+		path        string
+		statusCode  int
+		contentType string
+		body        string
+	}{
+		{"/render", http.StatusCreated, xmlContentType[0], "<person><name>cssivision</name><age>0</age><other></other></person>"},
+		{"/nocontent", http.StatusNoContent, jsonContentType[0], ""},
+		{"/xml", http.StatusOK, xmlContentType[0], "<person><name>cssivision</name><age>0</age><other></other></person>"},
+		{"/indented", http.StatusOK, jsonContentType[0], "[\n    1\n]\n"},
+		{"/jsonp?callback=cb", http.StatusOK, javascriptContentType[0], "/**/ typeof cb === 'function' && cb([1]);"},
+		{"/jsonp?callback=alert(1)", http.StatusBadRequest, plainContentType[0], ErrInvalidCallback.Error()},
+		{"/secure", http.StatusOK, jsonContentType[0], ")]}'\n[1]"},
+		{"/ascii", http.StatusOK, asciiJSONContentType[0], `"\u00e9"`},
+	}
+
+	for _, tc := range cases {
+		resp, err := http.Get(server.URL + tc.path)
+		assert.Nil(t, err)
+		bodyBytes, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Nil(t, err)
+		assert.Equal(t, tc.statusCode, resp.StatusCode, tc.path)
+		assert.Equal(t, tc.contentType, resp.Header.Get("Content-Type"), tc.path)
+		assert.Equal(t, tc.body, string(bodyBytes), tc.path)
+	}
+}
@@ -0,0 +1,160 @@
+package looli
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// packagePath is the import path of looli, used to find the caller outside of looli.
+var packagePath = reflect.TypeOf(Context{}).PkgPath()
+
+// responseWriter wraps http.ResponseWriter to track the status code and size of the response.
+// A status code written after the response was committed is dropped, in debug mode it is
+// reported with the caller's location.
+type responseWriter struct {
+	http.ResponseWriter
+
+	// status code of the response, default is http.StatusOK
+	status int
+
+	// number of bytes written to the body
+	size int
+
+	// written is true once the status code has been sent
+	written bool
+
+	// buffered is true once the status code is recorded by a writer which buffers the
+	// response, such as the one of Compress middleware, the header may not be sent yet
+	buffered bool
+
+	// debug reports misuse of the response
+	debug bool
+
+	// header sent to client, it is kept in debug mode to detect later modification
+	committedHeader http.Header
+}
+
+func newResponseWriter(rw http.ResponseWriter, debug bool) *responseWriter {
+	return &responseWriter{
+		ResponseWriter: rw,
+		status:         defaultStatusCode,
+		debug:          debug,
+	}
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.written {
+		w.superfluousWriteHeader(code)
+		return
+	}
+
+	w.status = code
+	w.written = true
+	if w.debug {
+		w.committedHeader = w.ResponseWriter.Header().Clone()
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// superfluousWriteHeader reports in debug mode the status code set after the response was
+// committed.
+func (w *responseWriter) superfluousWriteHeader(code int) {
+	if w.debug {
+		debugPrintf("status %d is set after the response was committed with status %d, at %s", code, w.status, callerLocation())
+	}
+}
+
+func (w *responseWriter) Write(data []byte) (int, error) {
+	if !w.written {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	n, err := w.ResponseWriter.Write(data)
+	w.size += n
+	return n, err
+}
+
+// Flush sends any buffered data to the client.
+func (w *responseWriter) Flush() {
+	if !w.written {
+		w.WriteHeader(http.StatusOK)
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack lets the caller take over the connection.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("the ResponseWriter doesn't support the Hijacker interface")
+	}
+
+	conn, buf, err := hijacker.Hijack()
+	if err == nil {
+		w.written = true
+	}
+	return conn, buf, err
+}
+
+// Unwrap returns the underlying http.ResponseWriter, it is used by http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// checkHeader reports the header modified after the response was committed.
+func (w *responseWriter) checkHeader() {
+	if !w.debug || w.committedHeader == nil {
+		return
+	}
+
+	header := w.ResponseWriter.Header()
+	for key, values := range header {
+		if !equalValues(values, w.committedHeader[key]) {
+			debugPrintf("header %q is modified after the response was committed", key)
+		}
+	}
+	for key := range w.committedHeader {
+		if _, ok := header[key]; !ok {
+			debugPrintf("header %q is deleted after the response was committed", key)
+		}
+	}
+}
+
+func equalValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// callerLocation returns file:line of the first caller outside of looli and net/http.
+func callerLocation() string {
+	pc := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pc[:runtime.Callers(2, pc)])
+	for {
+		frame, more := frames.Next()
+		internal := strings.HasPrefix(frame.Function, packagePath+".") && !strings.HasSuffix(frame.File, "_test.go")
+		if !internal && !strings.HasPrefix(frame.Function, "net/http.") {
+			return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
+
+func debugPrintf(format string, values ...interface{}) {
+	fmt.Fprintf(defaultErrorWriter, "[looli-debug] "+format+"\n", values...)
+}
@@ -0,0 +1,86 @@
+package looli
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseWriter(t *testing.T) {
+	router := New()
+	router.Use(func(c *Context) {
+		c.Next()
+		assert.Equal(t, http.StatusCreated, c.StatusCode())
+		assert.True(t, c.Written())
+		assert.Equal(t, len("created"), c.Size())
+	})
+	router.Get("/a", func(c *Context) {
+		assert.Equal(t, http.StatusOK, c.StatusCode())
+		assert.False(t, c.Written())
+		c.String(http.StatusCreated, "created")
+		c.Status(http.StatusInternalServerError)
+	})
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/a")
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, "created", string(bodyBytes))
+}
+
+func TestDebugCommitted(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	errorWriter := defaultErrorWriter
+	defaultErrorWriter = buffer
+	defer func() {
+		defaultErrorWriter = errorWriter
+	}()
+
+	router := New()
+	router.Debug = true
+	router.Get("/status", func(c *Context) {
+		c.String(http.StatusOK, "hello")
+		c.Status(http.StatusNotFound)
+	})
+	router.Get("/header", func(c *Context) {
+		c.String(http.StatusOK, "hello")
+		c.SetHeader("X-After", "1")
+	})
+	router.Get("/direct", func(c *Context) {
+		c.String(http.StatusOK, "hello")
+		c.ResponseWriter.Header().Set("X-Direct", "1")
+	})
+	router.Get("/ok", func(c *Context) {
+		c.SetHeader("X-Before", "1")
+		c.String(http.StatusOK, "hello")
+	})
+
+	cases := map[string]string{
+		"/status": "status 404 is set after the response was committed with status 200, at ",
+		"/header": "header X-After is set after the response was committed, at ",
+		"/direct": `header "X-Direct" is modified after the response was committed`,
+		"/ok":     "",
+	}
+
+	for path, message := range cases {
+		buffer.Reset()
+		issueRequest(t, router, http.MethodGet, path)
+		if message == "" {
+			assert.Empty(t, buffer.String(), path)
+		} else {
+			assert.Contains(t, buffer.String(), message, path)
+			if path != "/direct" {
+				assert.Contains(t, buffer.String(), "response_writer_test.go:", path)
+			}
+		}
+	}
+}
@@ -75,10 +75,16 @@ func (r *Router) Handle(method, pattern string, handlers []HandlerFunc) {
 		pattern = strings.ToLower(pattern)
 	}
 
+	r.allowMethod(method)
+	r.tree.insert(pattern).addHandlers(method, handlers)
+}
+
+// allowMethod marks method as registered, so requests with it are handled by NoRoute
+// instead of NoMethod when no route matches.
+func (r *Router) allowMethod(method string) {
 	if !r.allowMethods[method] {
 		r.allowMethods[method] = true
 	}
-	r.tree.insert(pattern).addHandlers(method, handlers)
 }
 
 func (r *Router) handleRequest(c *Context) {
@@ -96,6 +102,7 @@ func (r *Router) handleRequest(c *Context) {
 		if handlers := n.handlers[req.Method]; handlers != nil {
 			c.handlers = append(c.handlers, handlers...)
 			c.Params = ps
+			c.pattern = n.pattern
 			c.Next()
 			return
 		}
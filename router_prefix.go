@@ -1,6 +1,7 @@
 package looli
 
 import (
+	"io/fs"
 	"net/http"
 	"os"
 	"path"
@@ -8,8 +9,11 @@ import (
 )
 
 var (
+	default403Body = "403 forbidden\n"
 	default404Body = "404 page not found\n"
 	default405Body = "405 method not allowed\n"
+	default406Body = "406 not acceptable\n"
+	default500Body = "500 internal server error\n"
 )
 
 // RouterPrefix is used internally to configure router, a RouterPrefix is associated with a basePath
@@ -22,6 +26,9 @@ type RouterPrefix struct {
 	allNoRoute  []HandlerFunc
 	allNoMethod []HandlerFunc
 	isPrefix    bool
+
+	// htmlRenderer used by the routes of prefix, it takes precedence over the engine's
+	htmlRenderer HTMLRenderer
 }
 
 // Use adds middleware to the router.
@@ -102,7 +109,12 @@ func (p *RouterPrefix) Handle(method, pattern string, handlers ...HandlerFunc) {
 	}
 
 	if p.isPrefix {
+		// the renderer of prefix is applied before the middlewares of prefix
 		handlers = p.combineHandlers(handlers)
+		if len(handlers)+1 >= int(abortIndex) {
+			panic("too many handlers")
+		}
+		handlers = append([]HandlerFunc{p.applyHTMLRenderer}, handlers...)
 	}
 
 	p.router.Handle(method, pattern, handlers)
@@ -110,38 +122,84 @@ func (p *RouterPrefix) Handle(method, pattern string, handlers ...HandlerFunc) {
 
 // StaticFile register router pattern and response file in path
 func (p *RouterPrefix) StaticFile(pattern, filepath string) {
+	p.staticFile(pattern, func(c *Context) {
+		c.ServeFile(filepath)
+	})
+}
+
+// StaticFileFS register router pattern and response the named file in fsys, such as embed.FS
+func (p *RouterPrefix) StaticFileFS(pattern, name string, fsys fs.FS) {
+	p.staticFile(pattern, func(c *Context) {
+		c.FileFromFS(name, fsys)
+	})
+}
+
+func (p *RouterPrefix) staticFile(pattern string, handler HandlerFunc) {
 	if strings.Contains(pattern, ":") || strings.Contains(pattern, "*") {
 		panic("URL parameters can not be used when serving a static folder")
 	}
 
-	handler := func(c *Context) {
-		c.ServeFile(filepath)
-		if _, err := os.Stat(filepath); os.IsNotExist(err) {
-			c.statusCode = http.StatusNotFound
-		}
-	}
-
 	p.Head(pattern, handler)
 	p.Get(pattern, handler)
 }
 
-// Static register router pattern and response file in the request url
-func (p *RouterPrefix) Static(pattern, dir string) {
+// Static register router pattern and response file of dir in the request url. Directory
+// listings and hidden files are not served by default, pass StaticOptions to change it or
+// to set cache headers.
+//
+//	router.Static("/assets", "./assets", looli.StaticOptions{
+//		MaxAge: map[string]time.Duration{".css": 24 * time.Hour, "": 0},
+//	})
+//
+// A single-page application with Fallback registered at "/" is served when no route
+// matches, the missing files and the paths in FallbackExclude are passed to the NoRoute
+// handlers.
+//
+//	router.Static("/", "./build", looli.StaticOptions{
+//		Fallback:        "index.html",
+//		FallbackExclude: []string{"/api"},
+//	})
+func (p *RouterPrefix) Static(pattern, dir string, options ...StaticOptions) {
+	p.staticFileSystem(pattern, os.DirFS(dir), options)
+}
+
+// StaticFS register router pattern and response file of fsys in the request url, such as
+// embed.FS, use fs.Sub to serve a sub directory of fsys.
+//
+//	//go:embed assets
+//	var assets embed.FS
+//
+//	sub, _ := fs.Sub(assets, "assets")
+//	router.StaticFS("/static", sub)
+func (p *RouterPrefix) StaticFS(pattern string, fsys fs.FS, options ...StaticOptions) {
+	p.staticFileSystem(pattern, fsys, options)
+}
+
+func (p *RouterPrefix) staticFileSystem(pattern string, fsys fs.FS, options []StaticOptions) {
 	if strings.Contains(pattern, ":") || strings.Contains(pattern, "*") {
 		panic("URL parameters can not be used when serving a static folder")
 	}
 
-	fileServer := http.StripPrefix(pattern, http.FileServer(http.Dir(dir)))
-	handler := func(c *Context) {
-		fileServer.ServeHTTP(c.ResponseWriter, c.Request)
-		if _, err := os.Stat(path.Join(dir, c.Param("filepath"))); os.IsNotExist(err) {
-			c.statusCode = http.StatusNotFound
-		}
+	h := newStaticHandler(fsys, options)
+	h.urlPrefix = path.Join("/", p.basePath, pattern)
+	if h.options.Fingerprint {
+		p.engine.assets = append(p.engine.assets, h)
+	}
+
+	// a single-page application at the root is served when no route matches, so that it
+	// doesn't conflict with other routes. GET and HEAD are allowed even if no route is
+	// registered with them, otherwise the requests are handled by NoMethod.
+	if h.urlPrefix == "/" && h.options.Fallback != "" {
+		p.router.allowMethod(http.MethodGet)
+		p.router.allowMethod(http.MethodHead)
+		p.engine.staticNoRoute = append(p.engine.staticNoRoute, h.serveNoRoute)
+		p.engine.router.NoRoute = p.engine.combineNoRoute()
+		return
 	}
 
 	urlPattern := path.Join(pattern, "/*filepath")
-	p.Head(urlPattern, handler)
-	p.Get(urlPattern, handler)
+	p.Head(urlPattern, h.serve)
+	p.Get(urlPattern, h.serve)
 }
 
 // combine middleware and handlers for specific route
@@ -174,6 +232,7 @@ func (p *RouterPrefix) composeMiddlewares() func(http.ResponseWriter, *http.Requ
 		handlers := p.combineHandlers([]HandlerFunc{httpHandler})
 		context.handlers = handlers
 		context.Next()
+		context.writer.checkHeader()
 	}
 }
 
@@ -2,6 +2,8 @@ package looli
 
 import (
 	"bytes"
+	"embed"
+	"io/fs"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
@@ -58,8 +60,7 @@ func handlePostPutMethod(method string, t *testing.T) {
 		assert.Nil(t, err)
 
 		assert.Equal(t, requestData, requestBody)
-		c.Status(statusCode)
-		c.String(serverResponse)
+		c.String(statusCode, serverResponse)
 	})
 
 	server := httptest.NewServer(router)
@@ -105,8 +106,7 @@ func handleMethod(method string, t *testing.T) {
 	statusCode := 404
 	router := New()
 	router.Handle(method, "/a/b", func(c *Context) {
-		c.Status(statusCode)
-		c.String(serverResponse)
+		c.String(statusCode, serverResponse)
 	})
 
 	server := httptest.NewServer(router)
@@ -238,6 +238,67 @@ func TestStatic(t *testing.T) {
 	})
 }
 
+//go:embed test/index.html test/templates
+var testFS embed.FS
+
+func TestStaticFS(t *testing.T) {
+	router := New()
+	router.StaticFS("/assets", testFS)
+	sub, err := fs.Sub(testFS, "test")
+	assert.Nil(t, err)
+	router.Prefix("/prefix").StaticFS("/static", sub)
+	router.StaticFileFS("/index", "test/index.html", testFS)
+	router.StaticFileFS("/missing", "test/missing.html", testFS)
+	router.StaticFileFS("/dir", "test/templates", testFS)
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	content, err := ioutil.ReadFile("test/index.html")
+	assert.Nil(t, err)
+
+	cases := []struct {
+		path        string
+		statusCode  int
+		contentType string
+	}{
+		{"/assets/test/index.html", http.StatusMovedPermanently, ""},
+		{"/assets/test/templates/index.tmpl", http.StatusOK, "text/html; charset=utf-8"},
+		{"/assets/test/missing.html", http.StatusNotFound, "text/plain; charset=utf-8"},
+		{"/prefix/static/templates/index.tmpl", http.StatusOK, "text/html; charset=utf-8"},
+		{"/index", http.StatusOK, "text/html; charset=utf-8"},
+		{"/missing", http.StatusNotFound, "text/plain; charset=utf-8"},
+		{"/dir", http.StatusNotFound, "text/plain; charset=utf-8"},
+	}
+
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	for _, tc := range cases {
+		resp, err := client.Get(server.URL + tc.path)
+		assert.Nil(t, err)
+		bodyBytes, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Nil(t, err)
+		assert.Equal(t, tc.statusCode, resp.StatusCode, tc.path)
+		if tc.contentType != "" {
+			assert.Equal(t, tc.contentType, resp.Header.Get("Content-Type"), tc.path)
+		}
+		if tc.path == "/index" {
+			assert.Equal(t, content, bodyBytes)
+		}
+	}
+
+	assert.Panics(t, func() {
+		router.StaticFS("/:a", testFS)
+	})
+	assert.Panics(t, func() {
+		router.StaticFileFS("/*a", "test/index.html", testFS)
+	})
+}
+
 func TestPrefix(t *testing.T) {
 	router := New()
 	serverResponse := "server response"
@@ -247,8 +308,7 @@ func TestPrefix(t *testing.T) {
 	assert.NotNil(t, v1.router)
 	assert.Equal(t, v1.basePath, "/v1")
 	v1.Get("/a/b", func(c *Context) {
-		c.Status(statusCode)
-		c.String(serverResponse)
+		c.String(statusCode, serverResponse)
 	})
 
 	server := httptest.NewServer(router)
@@ -279,8 +339,7 @@ func TestPrefixUse(t *testing.T) {
 		})
 
 		router.Get("/a/b", func(c *Context) {
-			c.Status(statusCode)
-			c.String(serverResponse)
+			c.String(statusCode, serverResponse)
 		})
 
 		v1 := router.Prefix("/v1")
@@ -289,8 +348,7 @@ func TestPrefixUse(t *testing.T) {
 		})
 
 		v1.Get("/a/b", func(c *Context) {
-			c.Status(statusCode)
-			c.String(serverResponse)
+			c.String(statusCode, serverResponse)
 		})
 
 		server := httptest.NewServer(router)
@@ -342,7 +400,7 @@ func TestPrefixUse(t *testing.T) {
 		middleware2 := func(c *Context) {
 			c.SetHeader("response-fake-header", "fake")
 			c.Next()
-			c.String(serverResponse)
+			c.String(c.StatusCode(), serverResponse)
 		}
 		router := New()
 		v1 := router.Prefix("/v1")
@@ -381,8 +439,7 @@ func TestLoadHTMLGlob(t *testing.T) {
 	router := New()
 	router.LoadHTMLGlob("test/templates/*")
 	router.Get("/index.html", func(c *Context) {
-		c.Status(statusCode)
-		c.HTML("index.tmpl", JSON{
+		c.HTML(statusCode, "index.tmpl", JSON{
 			"title": "Posts",
 		})
 	})
@@ -410,8 +467,7 @@ func TestLoadHTMLFiles(t *testing.T) {
 	router := New()
 	router.LoadHTMLFiles("test/templates/index.tmpl")
 	router.Get("/index.html", func(c *Context) {
-		c.Status(statusCode)
-		c.HTML("index.tmpl", JSON{
+		c.HTML(statusCode, "index.tmpl", JSON{
 			"title": "Posts",
 		})
 	})
@@ -446,7 +502,7 @@ func TestUse(t *testing.T) {
 		middleware2 := func(c *Context) {
 			c.SetHeader("response-fake-header", "fake")
 			c.Next()
-			c.String(serverResponse)
+			c.String(c.StatusCode(), serverResponse)
 		}
 		router := New()
 		router.Use(middleware1, middleware2)
@@ -510,7 +566,7 @@ func TestUseHandler(t *testing.T) {
 		HandlerFunc: func(c *Context) {
 			c.SetHeader("response-fake-header", "fake")
 			c.Next()
-			c.String(serverResponse)
+			c.String(c.StatusCode(), serverResponse)
 		},
 	}
 
@@ -45,7 +45,7 @@ func TestCookieStoreGetSave(t *testing.T) {
 
 			sess.Values["name"] = "cssivision"
 			assert.Nil(t, sess.Save(ctx))
-			ctx.String(serverResponse)
+			ctx.String(http.StatusOK, serverResponse)
 		})
 
 		server := httptest.NewServer(router)
@@ -78,7 +78,7 @@ func TestCookieStoreGetSave(t *testing.T) {
 
 			sess.Values["name"] = "cssivision"
 			assert.Nil(t, sess.Save(ctx))
-			ctx.String(serverResponse)
+			ctx.String(http.StatusOK, serverResponse)
 		})
 
 		server := httptest.NewServer(router)
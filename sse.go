@@ -0,0 +1,367 @@
+package looli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var sseContentType = []string{"text/event-stream"}
+
+// default settings of Broker
+const (
+	defaultBrokerHistory    = 100
+	defaultBrokerHeartbeat  = 15 * time.Second
+	defaultBrokerBufferSize = 16
+)
+
+// Event is a Server-Sent Event.
+type Event struct {
+	// ID is sent to the client, the client sends it back in Last-Event-ID header when reconnecting.
+	ID string
+
+	// Event is the event name, the client dispatches the event to the listeners of this name,
+	// "message" is used by the client if it is empty.
+	Event string
+
+	// Data is the payload, string and []byte are sent as they are, others are encoded as JSON.
+	// Multi-line data is split into multiple data fields.
+	Data interface{}
+
+	// Retry tells the client how long to wait before reconnecting, it is not sent if zero.
+	Retry time.Duration
+}
+
+// encode writes event in text/event-stream format.
+func (event Event) encode(w io.Writer) error {
+	var buf strings.Builder
+	if event.ID != "" {
+		buf.WriteString("id: " + sanitizeEventField(event.ID) + "\n")
+	}
+	if event.Event != "" {
+		buf.WriteString("event: " + sanitizeEventField(event.Event) + "\n")
+	}
+	if event.Retry > 0 {
+		buf.WriteString("retry: " + strconv.FormatInt(int64(event.Retry/time.Millisecond), 10) + "\n")
+	}
+
+	var data string
+	switch value := event.Data.(type) {
+	case nil:
+	case string:
+		data = value
+	case []byte:
+		data = string(value)
+	default:
+		jsonBytes, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		data = string(jsonBytes)
+	}
+
+	if event.Data != nil {
+		data = strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(data)
+		for _, line := range strings.Split(data, "\n") {
+			buf.WriteString("data: " + line + "\n")
+		}
+	}
+	buf.WriteString("\n")
+
+	_, err := io.WriteString(w, buf.String())
+	return err
+}
+
+// sanitizeEventField removes line breaks which would end the field early.
+func sanitizeEventField(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
+
+// SSEvent writes a Server-Sent Event with name and data and flushes it to the client. The
+// headers of event stream are set before the first event.
+//
+//	c.SSEvent("message", looli.JSON{"text": "hello"})
+func (c *Context) SSEvent(name string, data interface{}) error {
+	return c.SendEvent(Event{Event: name, Data: data})
+}
+
+// SendEvent writes event and flushes it to the client, the headers of event stream are set
+// before the first event.
+func (c *Context) SendEvent(event Event) error {
+	c.prepareEventStream()
+	if err := event.encode(c.ResponseWriter); err != nil {
+		return err
+	}
+	c.Flush()
+	return nil
+}
+
+// Heartbeat writes a comment line to keep the connection open through proxies, it is
+// ignored by the client.
+func (c *Context) Heartbeat() error {
+	c.prepareEventStream()
+	if _, err := io.WriteString(c.ResponseWriter, ": heartbeat\n\n"); err != nil {
+		return err
+	}
+	c.Flush()
+	return nil
+}
+
+// LastEventID returns the id of the last event received by the client before reconnecting.
+func (c *Context) LastEventID() string {
+	return c.Header("Last-Event-ID")
+}
+
+func (c *Context) prepareEventStream() {
+	if c.Written() {
+		return
+	}
+
+	header := c.ResponseWriter.Header()
+	setContentType(c.ResponseWriter, sseContentType)
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+	c.Status(defaultStatusCode)
+}
+
+// Broker publishes Server-Sent Events to the subscribers of topics. It keeps a bounded
+// history of every topic, a client reconnecting with Last-Event-ID receives the events it
+// missed. Subscribers are removed when the client disconnects, a subscriber which can not
+// keep up is disconnected and replays the missed events when it reconnects.
+//
+//	broker := looli.NewBroker()
+//	router.Get("/events/:topic", func(c *looli.Context) {
+//		broker.Serve(c, c.Param("topic"))
+//	})
+//	broker.Publish("news", "message", looli.JSON{"title": "hello"})
+type Broker struct {
+	// History is the number of events kept for each topic to replay, default is 100.
+	History int
+
+	// Heartbeat is the interval to send heartbeat to subscribers, default is 15 seconds,
+	// negative value disables heartbeat.
+	Heartbeat time.Duration
+
+	// Retry is sent to the client when it subscribes, it is not sent if zero.
+	Retry time.Duration
+
+	// BufferSize is the number of events buffered for each subscriber, default is 16.
+	BufferSize int
+
+	mu     sync.Mutex
+	lastID uint64
+	topics map[string]*brokerTopic
+}
+
+type brokerTopic struct {
+	// history is a ring buffer of the last events, next is where the next event is written
+	// once it is full
+	history     []brokerEvent
+	next        int
+	subscribers map[chan Event]struct{}
+}
+
+type brokerEvent struct {
+	id    uint64
+	event Event
+}
+
+// record adds event to the history which keeps the last limit events.
+func (t *brokerTopic) record(event brokerEvent, limit int) {
+	if len(t.history) != limit && t.next != 0 {
+		// the limit is changed, restore the order before resizing
+		t.history = append(append([]brokerEvent(nil), t.history[t.next:]...), t.history[:t.next]...)
+		t.next = 0
+	}
+	if over := len(t.history) - limit; over > 0 {
+		t.history = append([]brokerEvent(nil), t.history[over:]...)
+	}
+
+	if len(t.history) < limit {
+		t.history = append(t.history, event)
+		return
+	}
+	t.history[t.next] = event
+	t.next = (t.next + 1) % limit
+}
+
+// replay returns the events in history with id greater than lastID, oldest first.
+func (t *brokerTopic) replay(lastID uint64) []Event {
+	var events []Event
+	for i := range t.history {
+		e := t.history[(t.next+i)%len(t.history)]
+		if e.id > lastID {
+			events = append(events, e.event)
+		}
+	}
+	return events
+}
+
+// NewBroker returns a Broker with default settings.
+func NewBroker() *Broker {
+	return &Broker{
+		History:    defaultBrokerHistory,
+		Heartbeat:  defaultBrokerHeartbeat,
+		BufferSize: defaultBrokerBufferSize,
+		topics:     make(map[string]*brokerTopic),
+	}
+}
+
+func (b *Broker) topic(name string) *brokerTopic {
+	if b.topics == nil {
+		b.topics = make(map[string]*brokerTopic)
+	}
+	t, ok := b.topics[name]
+	if !ok {
+		t = &brokerTopic{subscribers: make(map[chan Event]struct{})}
+		b.topics[name] = t
+	}
+	return t
+}
+
+// Publish sends an event with name and data to the subscribers of topic and returns it, the
+// event is assigned an id which increases across all topics of the broker.
+func (b *Broker) Publish(topic, name string, data interface{}) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	event := Event{ID: strconv.FormatUint(b.lastID, 10), Event: name, Data: data}
+
+	t, ok := b.topics[topic]
+	if !ok && b.History <= 0 {
+		// nobody receives or replays the event
+		return event
+	}
+	if !ok {
+		t = b.topic(topic)
+	}
+	if b.History > 0 {
+		t.record(brokerEvent{id: b.lastID, event: event}, b.History)
+	}
+
+	for ch := range t.subscribers {
+		select {
+		case ch <- event:
+		default:
+			delete(t.subscribers, ch)
+			close(ch)
+		}
+	}
+	b.removeIdle(topic, t)
+	return event
+}
+
+// removeIdle deletes topic t if it has neither subscribers nor history.
+func (b *Broker) removeIdle(topic string, t *brokerTopic) {
+	if len(t.subscribers) == 0 && len(t.history) == 0 && b.topics[topic] == t {
+		delete(b.topics, topic)
+	}
+}
+
+// Subscribe registers a subscriber of topic, it returns the events in history published after
+// lastEventID, the channel of new events and a function to unsubscribe. The channel is closed
+// when the subscriber is unsubscribed or can not keep up.
+func (b *Broker) Subscribe(topic, lastEventID string) (replay []Event, events <-chan Event, cancel func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	t := b.topic(topic)
+	if lastEventID != "" {
+		if lastID, err := strconv.ParseUint(lastEventID, 10, 64); err == nil {
+			replay = t.replay(lastID)
+		}
+	}
+
+	bufferSize := b.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = defaultBrokerBufferSize
+	}
+	ch := make(chan Event, bufferSize)
+	t.subscribers[ch] = struct{}{}
+
+	var once sync.Once
+	cancel = func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if _, ok := t.subscribers[ch]; ok {
+				delete(t.subscribers, ch)
+				close(ch)
+			}
+			b.removeIdle(topic, t)
+		})
+	}
+	return replay, ch, cancel
+}
+
+// Subscribers returns the number of subscribers of topic.
+func (b *Broker) Subscribers(topic string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if t, ok := b.topics[topic]; ok {
+		return len(t.subscribers)
+	}
+	return 0
+}
+
+// Serve streams the events of topic to the client until it disconnects, the events missed
+// since Last-Event-ID are sent first.
+func (b *Broker) Serve(c *Context, topic string) {
+	replay, events, cancel := b.Subscribe(topic, c.LastEventID())
+	defer cancel()
+
+	c.prepareEventStream()
+	if b.Retry > 0 {
+		fmt.Fprintf(c.ResponseWriter, "retry: %d\n\n", b.Retry/time.Millisecond)
+	}
+	for _, event := range replay {
+		if err := event.encode(c.ResponseWriter); err != nil {
+			return
+		}
+	}
+	c.Flush()
+
+	var heartbeat <-chan time.Time
+	interval := b.Heartbeat
+	if interval == 0 {
+		interval = defaultBrokerHeartbeat
+	}
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	done := c.Request.Context().Done()
+	for {
+		select {
+		case <-done:
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if err := c.SendEvent(event); err != nil {
+				return
+			}
+		case <-heartbeat:
+			if err := c.Heartbeat(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Handler returns a HandlerFunc which serves the events of topic.
+func (b *Broker) Handler(topic string) HandlerFunc {
+	return func(c *Context) {
+		b.Serve(c, topic)
+	}
+}
@@ -0,0 +1,196 @@
+package looli
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSSEvent(t *testing.T) {
+	router := New()
+	router.Get("/", func(c *Context) {
+		assert.Nil(t, c.SSEvent("message", "hello"))
+		assert.Nil(t, c.SendEvent(Event{
+			ID:    "2",
+			Event: "multi\nline",
+			Data:  "line1\r\nline2\rline3",
+			Retry: 3 * time.Second,
+		}))
+		assert.Nil(t, c.SSEvent("json", JSON{"a": 1}))
+		assert.Nil(t, c.Heartbeat())
+		assert.NotNil(t, c.SSEvent("invalid", make(chan int)))
+	})
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "no-cache", resp.Header.Get("Cache-Control"))
+	assert.Equal(t, "event: message\ndata: hello\n\n"+
+		"id: 2\nevent: multiline\nretry: 3000\ndata: line1\ndata: line2\ndata: line3\n\n"+
+		"event: json\ndata: {\"a\":1}\n\n"+
+		": heartbeat\n\n", string(bodyBytes))
+}
+
+// readEvent reads the fields of the next event, comments are skipped.
+func readEvent(t *testing.T, reader *bufio.Reader) []string {
+	var fields []string
+	for {
+		line, err := reader.ReadString('\n')
+		assert.Nil(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			if len(fields) > 0 {
+				return fields
+			}
+			continue
+		}
+		if !strings.HasPrefix(line, ":") {
+			fields = append(fields, line)
+		}
+	}
+}
+
+func waitSubscribers(t *testing.T, broker *Broker, topic string, n int) {
+	for i := 0; i < 500; i++ {
+		if broker.Subscribers(topic) == n {
+			return
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	t.Fatalf("expect %d subscribers of %s, got %d", n, topic, broker.Subscribers(topic))
+}
+
+func TestBroker(t *testing.T) {
+	broker := NewBroker()
+	broker.History = 2
+	broker.Retry = time.Second
+
+	router := New()
+	router.Get("/events/:topic", func(c *Context) {
+		broker.Serve(c, c.Param("topic"))
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	t.Run("publish to subscribers", func(t *testing.T) {
+		resp, err := http.Get(server.URL + "/events/news")
+		assert.Nil(t, err)
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+		reader := bufio.NewReader(resp.Body)
+		assert.Equal(t, []string{"retry: 1000"}, readEvent(t, reader))
+
+		waitSubscribers(t, broker, "news", 1)
+		broker.Publish("other", "message", "ignored")
+		event := broker.Publish("news", "message", JSON{"title": "hello"})
+		assert.Equal(t, []string{"id: " + event.ID, "event: message", `data: {"title":"hello"}`}, readEvent(t, reader))
+
+		resp.Body.Close()
+		waitSubscribers(t, broker, "news", 0)
+	})
+
+	t.Run("replay after reconnect", func(t *testing.T) {
+		first := broker.Publish("replay", "message", "1")
+		broker.Publish("replay", "message", "2")
+		broker.Publish("replay", "message", "3")
+		broker.Publish("replay", "message", "4")
+
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/events/replay", nil)
+		req.Header.Set("Last-Event-ID", first.ID)
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		reader := bufio.NewReader(resp.Body)
+
+		// only the last 2 events are kept
+		assert.Equal(t, []string{"retry: 1000"}, readEvent(t, reader))
+		assert.Equal(t, "data: 3", readEvent(t, reader)[2])
+		assert.Equal(t, "data: 4", readEvent(t, reader)[2])
+	})
+
+	t.Run("slow subscriber", func(t *testing.T) {
+		replay, events, cancel := broker.Subscribe("slow", "")
+		defer cancel()
+		assert.Nil(t, replay)
+
+		for i := 0; i <= defaultBrokerBufferSize; i++ {
+			broker.Publish("slow", "message", i)
+		}
+		assert.Equal(t, 0, broker.Subscribers("slow"))
+
+		count := 0
+		for range events {
+			count++
+		}
+		assert.Equal(t, defaultBrokerBufferSize, count)
+	})
+}
+
+func TestBrokerTopics(t *testing.T) {
+	t.Run("remove idle topics", func(t *testing.T) {
+		broker := NewBroker()
+		broker.History = 0
+
+		broker.Publish("news", "message", "nobody")
+		assert.Empty(t, broker.topics)
+
+		_, _, cancel := broker.Subscribe("news", "")
+		assert.Len(t, broker.topics, 1)
+		cancel()
+		assert.Empty(t, broker.topics)
+
+		// the slow subscriber is removed by Publish
+		_, _, cancel = broker.Subscribe("slow", "")
+		defer cancel()
+		for i := 0; i <= defaultBrokerBufferSize; i++ {
+			broker.Publish("slow", "message", i)
+		}
+		assert.Empty(t, broker.topics)
+
+		// topics with history are kept to replay
+		broker.History = 1
+		broker.Publish("news", "message", "kept")
+		assert.Len(t, broker.topics, 1)
+	})
+
+	t.Run("history ring", func(t *testing.T) {
+		broker := NewBroker()
+		broker.History = 3
+		replayData := func() []interface{} {
+			replay, _, cancel := broker.Subscribe("news", "0")
+			defer cancel()
+			var data []interface{}
+			for _, event := range replay {
+				data = append(data, event.Data)
+			}
+			return data
+		}
+
+		for i := 1; i <= 5; i++ {
+			broker.Publish("news", "message", i)
+		}
+		assert.Equal(t, []interface{}{3, 4, 5}, replayData())
+
+		broker.History = 2
+		broker.Publish("news", "message", 6)
+		assert.Equal(t, []interface{}{5, 6}, replayData())
+
+		broker.History = 4
+		broker.Publish("news", "message", 7)
+		broker.Publish("news", "message", 8)
+		broker.Publish("news", "message", 9)
+		assert.Equal(t, []interface{}{6, 7, 8, 9}, replayData())
+	})
+}
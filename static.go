@@ -0,0 +1,517 @@
+package looli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultIndexFile is served for directories by Static and StaticFS.
+const defaultIndexFile = "index.html"
+
+// StaticOptions configures Static and StaticFS, the zero value is the safe default: no
+// directory listings, hidden files are not served, "index.html" is served for directories
+// and ETag is set.
+type StaticOptions struct {
+	// Browse lists the content of directories without index file.
+	Browse bool
+
+	// AllowHidden serves files and directories whose names start with ".", such as .env
+	// and .git/config, they are responded with 404 by default.
+	AllowHidden bool
+
+	// Index is the file served for directories, default is "index.html".
+	Index string
+
+	// MaxAge sets Cache-Control and Expires headers by lower case file extension, such as ".css", the
+	// key "" is used for other files. Zero duration sets "Cache-Control: no-cache".
+	MaxAge map[string]time.Duration
+
+	// NoETag disables the ETag header, which is computed from the size and modification time.
+	NoETag bool
+
+	// Precompressed serves the variant "app.js.br" or "app.js.gz" of "app.js" with
+	// Content-Encoding header if it exists and the client accepts the encoding, brotli is
+	// preferred over gzip.
+	Precompressed bool
+
+	// Fingerprint serves the files under names with the hash of content, such as
+	// "app.3f2a1c9e.js" for "app.js", with "Cache-Control: public, max-age=31536000, immutable".
+	// The names are generated by Engine.Asset and the "asset" template function.
+	Fingerprint bool
+
+	// Fallback is the file served for the missing paths without extension, such as
+	// "index.html" for single-page applications whose routes are handled by the client.
+	// Paths with extension are assets, they are responded with 404 when missing.
+	Fallback string
+
+	// FallbackExclude are URL path prefixes which Fallback is not served for, such as "/api".
+	FallbackExclude []string
+}
+
+// staticHandler serves the files of fsys with options.
+type staticHandler struct {
+	fsys    fs.FS
+	options StaticOptions
+
+	// urlPrefix is the path which the files are served under, used by Engine.Asset
+	urlPrefix string
+
+	// hashes of the files for fingerprint, keyed by name
+	mu     sync.Mutex
+	hashes map[string]assetHash
+}
+
+// assetHash is the fingerprint of a file, it is computed again when the file changes.
+type assetHash struct {
+	size    int64
+	modTime time.Time
+	hash    string
+}
+
+// encodings of precompressed files in order of preference, with their file extensions
+var precompressedEncodings = []struct {
+	encoding string
+	ext      string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// length of the hex encoded hash in fingerprinted names
+const fingerprintLength = 8
+
+// immutableMaxAge is the max age of fingerprinted files, which never change.
+const immutableMaxAge = 365 * 24 * time.Hour
+
+func newStaticHandler(fsys fs.FS, options []StaticOptions) *staticHandler {
+	h := &staticHandler{fsys: fsys, hashes: make(map[string]assetHash)}
+	if len(options) > 0 {
+		h.options = options[0]
+	}
+	if h.options.Index == "" {
+		h.options.Index = defaultIndexFile
+	}
+	return h
+}
+
+func (h *staticHandler) serve(c *Context) {
+	if err := h.serveName(c, c.Param("filepath")); err != nil {
+		c.fileError(err)
+	}
+}
+
+// serveNoRoute serves the files of the static directory registered at "/" when no route
+// matches, the request is passed to the next NoRoute handler if the file doesn't exist.
+func (h *staticHandler) serveNoRoute(c *Context) {
+	method := c.Request.Method
+	if method != http.MethodGet && method != http.MethodHead {
+		return
+	}
+
+	if err := h.serveName(c, c.Request.URL.Path); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return
+		}
+		c.fileError(err)
+	}
+	c.Abort()
+}
+
+// serveName serves the file name, the error is returned without writing response if the
+// file can't be served.
+func (h *staticHandler) serveName(c *Context, name string) error {
+	name = path.Clean("/" + name)
+	if !h.options.AllowHidden && isHiddenPath(name) {
+		return fs.ErrNotExist
+	}
+
+	name = strings.TrimPrefix(name, "/")
+	if name == "" {
+		name = "."
+	}
+
+	info, err := fs.Stat(h.fsys, name)
+	if err != nil {
+		if original, ok := h.resolveFingerprint(name); ok {
+			h.serveFile(c, original, true)
+			return nil
+		}
+		if errors.Is(err, fs.ErrNotExist) && h.fallback(c.Request.URL.Path) {
+			h.serveFile(c, h.options.Fallback, false)
+			return nil
+		}
+		return err
+	}
+
+	if info.IsDir() {
+		// redirect to the canonical path of directory, so relative links resolve
+		if urlPath := c.Request.URL.Path; !strings.HasSuffix(urlPath, "/") {
+			localRedirect(c, path.Base(urlPath)+"/")
+			return nil
+		}
+
+		index := path.Join(name, h.options.Index)
+		if indexInfo, err := fs.Stat(h.fsys, index); err == nil && !indexInfo.IsDir() {
+			h.serveFile(c, index, false)
+			return nil
+		}
+		if h.options.Browse {
+			h.listDirectory(c, name)
+			return nil
+		}
+		return fs.ErrNotExist
+	}
+
+	// the index file is served by its directory only
+	if path.Base(name) == h.options.Index {
+		localRedirect(c, "./")
+		return nil
+	}
+
+	h.serveFile(c, name, false)
+	return nil
+}
+
+// fallback reports whether the Fallback file is served for the missing file of urlPath,
+// paths with extension are assets and the paths in FallbackExclude are not served.
+func (h *staticHandler) fallback(urlPath string) bool {
+	if h.options.Fallback == "" || path.Ext(urlPath) != "" {
+		return false
+	}
+	for _, exclude := range h.options.FallbackExclude {
+		exclude = strings.TrimSuffix(exclude, "/")
+		if urlPath == exclude || strings.HasPrefix(urlPath, exclude+"/") {
+			return false
+		}
+	}
+	return true
+}
+
+// localRedirect redirects to target relative to the request path, keeping the query.
+func localRedirect(c *Context, target string) {
+	if c.Request.URL.RawQuery != "" {
+		target += "?" + c.Request.URL.RawQuery
+	}
+	c.ResponseWriter.Header().Set("Location", target)
+	c.Status(http.StatusMovedPermanently)
+}
+
+// serveFile serves the regular file name with cache headers and ETag, a precompressed
+// variant is served instead if the client accepts it. Fingerprinted files are immutable.
+func (h *staticHandler) serveFile(c *Context, name string, immutable bool) {
+	header := c.ResponseWriter.Header()
+	file, err := h.openPrecompressed(c, name)
+	if err != nil {
+		c.fileError(err)
+		return
+	}
+	if file == nil {
+		if file, err = h.fsys.Open(name); err != nil {
+			c.fileError(err)
+			return
+		}
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		c.fileError(err)
+		return
+	}
+
+	if immutable {
+		setMaxAge(header, immutableMaxAge, "immutable")
+	} else {
+		h.setCacheHeaders(header, path.Ext(name))
+	}
+	if !h.options.NoETag && header.Get("ETag") == "" {
+		header.Set("ETag", fileETag(info))
+	}
+
+	c.serveFile(file, path.Base(name), false)
+}
+
+// openPrecompressed opens the precompressed variant of name accepted by the client and sets
+// Content-Encoding and Content-Type headers, it returns nil if there is no such variant.
+func (h *staticHandler) openPrecompressed(c *Context, name string) (fs.File, error) {
+	if !h.options.Precompressed {
+		return nil, nil
+	}
+	// the Content-Type of variant can't be detected from its content
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		return nil, nil
+	}
+
+	header := c.ResponseWriter.Header()
+	addVaryHeader(header, "Accept-Encoding")
+	accepted := parseAcceptEncoding(c.Header("Accept-Encoding"))
+	for _, variant := range precompressedEncodings {
+		if acceptEncodingQuality(accepted, variant.encoding) <= 0 {
+			continue
+		}
+
+		info, err := fs.Stat(h.fsys, name+variant.ext)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		file, err := h.fsys.Open(name + variant.ext)
+		if err != nil {
+			return nil, err
+		}
+		header.Set("Content-Encoding", variant.encoding)
+		header.Set("Content-Type", contentType)
+		return file, nil
+	}
+	return nil, nil
+}
+
+// setCacheHeaders sets Cache-Control and Expires headers by the extension of file.
+func (h *staticHandler) setCacheHeaders(header http.Header, ext string) {
+	if h.options.MaxAge == nil {
+		return
+	}
+
+	maxAge, ok := h.options.MaxAge[strings.ToLower(ext)]
+	if !ok {
+		if maxAge, ok = h.options.MaxAge[""]; !ok {
+			return
+		}
+	}
+
+	if maxAge <= 0 {
+		header.Set("Cache-Control", "no-cache")
+		return
+	}
+	setMaxAge(header, maxAge, "")
+}
+
+// setMaxAge sets Cache-Control with maxAge and directive, and the matching Expires header.
+func setMaxAge(header http.Header, maxAge time.Duration, directive string) {
+	cacheControl := "public, max-age=" + strconv.FormatInt(int64(maxAge/time.Second), 10)
+	if directive != "" {
+		cacheControl += ", " + directive
+	}
+	header.Set("Cache-Control", cacheControl)
+	header.Set("Expires", time.Now().Add(maxAge).UTC().Format(http.TimeFormat))
+}
+
+// parseAcceptEncoding parses Accept-Encoding header into the q values of codings.
+func parseAcceptEncoding(acceptEncoding string) map[string]float64 {
+	codings := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		params := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(params[0]))
+		if coding == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range params[1:] {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok || strings.ToLower(strings.TrimSpace(key)) != "q" {
+				continue
+			}
+			var err error
+			q, err = strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || q < 0 || q > 1 {
+				q = 0
+			}
+		}
+		codings[coding] = q
+	}
+	return codings
+}
+
+// acceptEncodingQuality returns the q value of coding in codings, "*" matches the codings
+// not listed.
+func acceptEncodingQuality(codings map[string]float64, coding string) float64 {
+	if q, ok := codings[coding]; ok {
+		return q
+	}
+	// x-gzip is an alias of gzip
+	if coding == "gzip" {
+		if q, ok := codings["x-gzip"]; ok {
+			return q
+		}
+	}
+	return codings["*"]
+}
+
+// fileETag returns a weak ETag computed from the size and modification time of file.
+func fileETag(info fs.FileInfo) string {
+	return fmt.Sprintf(`W/"%x-%x"`, info.Size(), info.ModTime().UnixNano())
+}
+
+// isHiddenPath reports whether an element of the slash separated name starts with ".".
+func isHiddenPath(name string) bool {
+	for _, element := range strings.Split(name, "/") {
+		if strings.HasPrefix(element, ".") && element != "." && element != ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// fingerprint returns the hash of the content of file name, it is cached until the size or
+// modification time of file changes.
+func (h *staticHandler) fingerprint(name string) (string, error) {
+	if !h.options.AllowHidden && isHiddenPath(name) {
+		return "", fs.ErrNotExist
+	}
+	info, err := fs.Stat(h.fsys, name)
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", fs.ErrInvalid
+	}
+
+	h.mu.Lock()
+	cached, ok := h.hashes[name]
+	h.mu.Unlock()
+	if ok && cached.size == info.Size() && cached.modTime.Equal(info.ModTime()) {
+		return cached.hash, nil
+	}
+
+	file, err := h.fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))[:fingerprintLength]
+
+	h.mu.Lock()
+	h.hashes[name] = assetHash{size: info.Size(), modTime: info.ModTime(), hash: sum}
+	h.mu.Unlock()
+	return sum, nil
+}
+
+// resolveFingerprint returns the name of file which the fingerprinted name refers to, it
+// reports false if name is not fingerprinted or the hash does not match the content.
+func (h *staticHandler) resolveFingerprint(name string) (string, bool) {
+	if !h.options.Fingerprint {
+		return "", false
+	}
+
+	dir, base := path.Split(name)
+	elements := strings.Split(base, ".")
+	// the hash is before the extension, or the last element of names without extension
+	for _, i := range []int{len(elements) - 2, len(elements) - 1} {
+		if i < 1 || !isFingerprint(elements[i]) {
+			continue
+		}
+
+		original := dir + strings.Join(append(append([]string(nil), elements[:i]...), elements[i+1:]...), ".")
+		if hash, err := h.fingerprint(original); err == nil && hash == elements[i] {
+			return original, true
+		}
+	}
+	return "", false
+}
+
+// fingerprintName inserts hash into name before the extension.
+func fingerprintName(name, hash string) string {
+	dir, base := path.Split(name)
+	ext := path.Ext(base)
+	if ext == base {
+		ext = ""
+	}
+	return dir + strings.TrimSuffix(base, ext) + "." + hash + ext
+}
+
+func isFingerprint(s string) bool {
+	if len(s) != fingerprintLength {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil && strings.ToLower(s) == s
+}
+
+// Asset returns the URL of the fingerprinted file name served by Static or StaticFS with
+// Fingerprint option, such as "/static/app.3f2a1c9e.js" for "app.js". The file is looked up
+// in the order the directories are registered, the URL without fingerprint is returned if it
+// is not found. It is available as the "asset" function in templates.
+//
+//	<script src="{{ asset "app.js" }}"></script>
+func (engine *Engine) Asset(name string) string {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	for _, h := range engine.assets {
+		if hash, err := h.fingerprint(name); err == nil {
+			return path.Join(h.urlPrefix, fingerprintName(name, hash))
+		}
+	}
+	if len(engine.assets) > 0 {
+		return path.Join(engine.assets[0].urlPrefix, name)
+	}
+	return "/" + name
+}
+
+var directoryListing = template.Must(template.New("listing").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{ .Name }}</title></head>
+<body>
+<h1>{{ .Name }}</h1>
+<pre>
+{{ range .Entries }}<a href="{{ .URL }}">{{ .Name }}</a>
+{{ end }}</pre>
+</body>
+</html>
+`))
+
+// listDirectory writes the entries of directory name, hidden entries are omitted unless
+// AllowHidden is set.
+func (h *staticHandler) listDirectory(c *Context, name string) {
+	entries, err := fs.ReadDir(h.fsys, name)
+	if err != nil {
+		c.fileError(err)
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	type entry struct {
+		Name string
+		URL  string
+	}
+	listing := struct {
+		Name    string
+		Entries []entry
+	}{Name: c.Request.URL.Path}
+
+	for _, e := range entries {
+		entryName := e.Name()
+		if !h.options.AllowHidden && strings.HasPrefix(entryName, ".") {
+			continue
+		}
+		if e.IsDir() {
+			entryName += "/"
+		}
+		listing.Entries = append(listing.Entries, entry{
+			Name: entryName,
+			URL:  (&url.URL{Path: entryName}).String(),
+		})
+	}
+
+	c.Render(http.StatusOK, HTMLRender{Template: directoryListing, Data: listing})
+}
@@ -0,0 +1,502 @@
+package looli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeStaticFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		assert.Nil(t, os.MkdirAll(filepath.Dir(name), 0755))
+		assert.Nil(t, ioutil.WriteFile(name, []byte(content), 0644))
+	}
+	return dir
+}
+
+func getStatic(t *testing.T, url string, header http.Header) (*http.Response, string) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	assert.Nil(t, err)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Do(req)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	return resp, string(bodyBytes)
+}
+
+func TestStaticOptions(t *testing.T) {
+	dir := writeStaticFiles(t, map[string]string{
+		"app.css":          "body {}",
+		"app.js":           "alert(1)",
+		"logo.txt":         "logo",
+		".env":             "SECRET=1",
+		".git/config":      "[core]",
+		"docs/a.txt":       "a",
+		"docs/.hidden":     "hidden",
+		"docs/sub/b.txt":   "b",
+		"site/index.html":  "<p>index</p>",
+		"site/home.html":   "<p>home</p>",
+		"site/about.txt":   "about",
+		"site/<script>.go": "escaped",
+	})
+
+	t.Run("default", func(t *testing.T) {
+		router := New()
+		router.Static("/static", dir)
+		server := httptest.NewServer(router)
+		defer server.Close()
+
+		cases := []struct {
+			path       string
+			statusCode int
+			body       string
+		}{
+			{"/static/app.css", http.StatusOK, "body {}"},
+			{"/static/.env", http.StatusNotFound, default404Body},
+			{"/static/.git/config", http.StatusNotFound, default404Body},
+			{"/static/docs/../.env", http.StatusNotFound, default404Body},
+			{"/static/docs/", http.StatusNotFound, default404Body},
+			{"/static/site/", http.StatusOK, "<p>index</p>"},
+			{"/static/site/index.html", http.StatusMovedPermanently, ""},
+			{"/static/site", http.StatusMovedPermanently, ""},
+		}
+		for _, tc := range cases {
+			resp, body := getStatic(t, server.URL+tc.path, nil)
+			assert.Equal(t, tc.statusCode, resp.StatusCode, tc.path)
+			if tc.body != "" {
+				assert.Equal(t, tc.body, body, tc.path)
+			}
+			assert.Empty(t, resp.Header.Get("Cache-Control"), tc.path)
+		}
+
+		resp, _ := getStatic(t, server.URL+"/static/site?a=1", nil)
+		assert.Equal(t, "site/?a=1", resp.Header.Get("Location"))
+		resp, _ = getStatic(t, server.URL+"/static/site/index.html", nil)
+		assert.Equal(t, "./", resp.Header.Get("Location"))
+	})
+
+	t.Run("browse", func(t *testing.T) {
+		router := New()
+		router.Static("/static", dir, StaticOptions{Browse: true})
+		router.Static("/hidden", dir, StaticOptions{Browse: true, AllowHidden: true})
+		server := httptest.NewServer(router)
+		defer server.Close()
+
+		resp, body := getStatic(t, server.URL+"/static/docs/", nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
+		assert.Contains(t, body, `<a href="a.txt">a.txt</a>`)
+		assert.Contains(t, body, `<a href="sub/">sub/</a>`)
+		assert.True(t, strings.Index(body, "a.txt") < strings.Index(body, "sub/"))
+		assert.NotContains(t, body, ".hidden")
+
+		resp, body = getStatic(t, server.URL+"/static/", nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.NotContains(t, body, ".env")
+		assert.NotContains(t, body, ".git")
+
+		// directory with index file is not listed
+		resp, body = getStatic(t, server.URL+"/static/site/", nil)
+		assert.Equal(t, "<p>index</p>", body)
+
+		resp, body = getStatic(t, server.URL+"/hidden/docs/", nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Contains(t, body, `<a href=".hidden">.hidden</a>`)
+		resp, body = getStatic(t, server.URL+"/hidden/.env", nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "SECRET=1", body)
+	})
+
+	t.Run("escape listing", func(t *testing.T) {
+		router := New()
+		router.Static("/static", dir, StaticOptions{Browse: true, Index: "missing.html"})
+		server := httptest.NewServer(router)
+		defer server.Close()
+
+		_, body := getStatic(t, server.URL+"/static/site/", nil)
+		assert.Contains(t, body, "&lt;script&gt;.go")
+		assert.NotContains(t, body, "<script>")
+	})
+
+	t.Run("custom index", func(t *testing.T) {
+		router := New()
+		router.Static("/static", dir, StaticOptions{Index: "home.html"})
+		server := httptest.NewServer(router)
+		defer server.Close()
+
+		resp, body := getStatic(t, server.URL+"/static/site/", nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "<p>home</p>", body)
+
+		resp, body = getStatic(t, server.URL+"/static/site/index.html", nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "<p>index</p>", body)
+	})
+
+	t.Run("cache headers", func(t *testing.T) {
+		router := New()
+		router.Static("/static", dir, StaticOptions{
+			MaxAge: map[string]time.Duration{
+				".css": 24 * time.Hour,
+				"":     0,
+			},
+		})
+		router.Static("/css", dir, StaticOptions{
+			MaxAge: map[string]time.Duration{".css": time.Minute},
+		})
+		server := httptest.NewServer(router)
+		defer server.Close()
+
+		resp, _ := getStatic(t, server.URL+"/static/app.css", nil)
+		assert.Equal(t, "public, max-age=86400", resp.Header.Get("Cache-Control"))
+		expires, err := http.ParseTime(resp.Header.Get("Expires"))
+		assert.Nil(t, err)
+		assert.WithinDuration(t, time.Now().Add(24*time.Hour), expires, time.Minute)
+
+		resp, _ = getStatic(t, server.URL+"/static/logo.txt", nil)
+		assert.Equal(t, "no-cache", resp.Header.Get("Cache-Control"))
+		assert.Empty(t, resp.Header.Get("Expires"))
+
+		resp, _ = getStatic(t, server.URL+"/css/app.css", nil)
+		assert.Equal(t, "public, max-age=60", resp.Header.Get("Cache-Control"))
+		resp, _ = getStatic(t, server.URL+"/css/logo.txt", nil)
+		assert.Empty(t, resp.Header.Get("Cache-Control"))
+	})
+
+	t.Run("etag", func(t *testing.T) {
+		router := New()
+		router.Static("/static", dir)
+		router.Static("/noetag", dir, StaticOptions{NoETag: true})
+		server := httptest.NewServer(router)
+		defer server.Close()
+
+		resp, body := getStatic(t, server.URL+"/static/app.js", nil)
+		assert.Equal(t, "alert(1)", body)
+		etag := resp.Header.Get("ETag")
+		assert.True(t, strings.HasPrefix(etag, `W/"`))
+
+		resp, body = getStatic(t, server.URL+"/static/app.js", http.Header{"If-None-Match": {etag}})
+		assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+		assert.Empty(t, body)
+
+		resp, _ = getStatic(t, server.URL+"/static/app.js", http.Header{"If-None-Match": {`W/"other"`}})
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp, _ = getStatic(t, server.URL+"/noetag/app.js", nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Empty(t, resp.Header.Get("ETag"))
+	})
+}
+
+func TestStaticPrecompressed(t *testing.T) {
+	dir := writeStaticFiles(t, map[string]string{
+		"app.js":          "alert(1)",
+		"app.js.gz":       "gzip",
+		"app.js.br":       "brotli",
+		"style.css":       "body {}",
+		"style.css.gz":    "gzip",
+		"data.unknown":    "data",
+		"data.unknown.gz": "gzip",
+	})
+
+	router := New()
+	router.Static("/static", dir, StaticOptions{Precompressed: true})
+	router.Static("/plain", dir)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	cases := []struct {
+		path           string
+		acceptEncoding string
+		body           string
+		encoding       string
+		contentType    string
+	}{
+		{"/static/app.js", "gzip, deflate, br", "brotli", "br", "text/javascript; charset=utf-8"},
+		{"/static/app.js", "gzip", "gzip", "gzip", "text/javascript; charset=utf-8"},
+		{"/static/app.js", "br;q=0, gzip;q=0.5", "gzip", "gzip", "text/javascript; charset=utf-8"},
+		{"/static/app.js", "*", "brotli", "br", "text/javascript; charset=utf-8"},
+		{"/static/app.js", "x-gzip", "gzip", "gzip", "text/javascript; charset=utf-8"},
+		{"/static/app.js", "identity", "alert(1)", "", "text/javascript; charset=utf-8"},
+		{"/static/app.js", "", "alert(1)", "", "text/javascript; charset=utf-8"},
+		{"/static/style.css", "br, gzip", "gzip", "gzip", "text/css; charset=utf-8"},
+		{"/static/style.css", "br", "body {}", "", "text/css; charset=utf-8"},
+		{"/static/data.unknown", "gzip", "data", "", ""},
+		{"/plain/app.js", "gzip, br", "alert(1)", "", "text/javascript; charset=utf-8"},
+	}
+	for _, tc := range cases {
+		// set Accept-Encoding explicitly so the transport doesn't decompress the body
+		resp, body := getStatic(t, server.URL+tc.path, http.Header{"Accept-Encoding": {tc.acceptEncoding}})
+		assert.Equal(t, http.StatusOK, resp.StatusCode, tc.path)
+		assert.Equal(t, tc.body, body, tc.path+" "+tc.acceptEncoding)
+		assert.Equal(t, tc.encoding, resp.Header.Get("Content-Encoding"), tc.path+" "+tc.acceptEncoding)
+		assert.Equal(t, strconv.Itoa(len(tc.body)), resp.Header.Get("Content-Length"))
+		if tc.contentType != "" {
+			assert.Equal(t, tc.contentType, resp.Header.Get("Content-Type"), tc.path)
+		}
+		if strings.HasPrefix(tc.path, "/static/") && tc.contentType != "" {
+			assert.Equal(t, "Accept-Encoding", resp.Header.Get("Vary"), tc.path)
+		} else {
+			assert.Empty(t, resp.Header.Get("Vary"), tc.path)
+		}
+	}
+
+	// the variants have different ETags
+	resp, _ := getStatic(t, server.URL+"/static/app.js", http.Header{"Accept-Encoding": {"br"}})
+	brETag := resp.Header.Get("ETag")
+	resp, _ = getStatic(t, server.URL+"/static/app.js", http.Header{"Accept-Encoding": {"gzip"}})
+	assert.NotEqual(t, brETag, resp.Header.Get("ETag"))
+	resp, _ = getStatic(t, server.URL+"/static/app.js", http.Header{"Accept-Encoding": {"br"}, "If-None-Match": {brETag}})
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+}
+
+func TestStaticFingerprint(t *testing.T) {
+	dir := writeStaticFiles(t, map[string]string{
+		"app.js":          "alert(1)",
+		"app.js.gz":       "gzip",
+		"css/app.min.css": "body {}",
+		"LICENSE":         "MIT",
+		".env":            "SECRET=1",
+	})
+	hash := func(content string) string {
+		sum := sha256.Sum256([]byte(content))
+		return hex.EncodeToString(sum[:])[:8]
+	}
+	appJS := "/site/static/app." + hash("alert(1)") + ".js"
+	appCSS := "/site/static/css/app.min." + hash("body {}") + ".css"
+	license := "/site/static/LICENSE." + hash("MIT")
+
+	router := New()
+	router.Prefix("/site").Static("/static", dir, StaticOptions{
+		Fingerprint:   true,
+		Precompressed: true,
+		MaxAge:        map[string]time.Duration{"": time.Minute},
+	})
+
+	assert.Equal(t, appJS, router.Asset("app.js"))
+	assert.Equal(t, appJS, router.Asset("/app.js"))
+	assert.Equal(t, appCSS, router.Asset("css/app.min.css"))
+	assert.Equal(t, license, router.Asset("LICENSE"))
+	assert.Equal(t, "/site/static/missing.js", router.Asset("missing.js"))
+	assert.Equal(t, "/site/static/.env", router.Asset(".env"))
+	assert.Equal(t, "/app.js", New().Asset("app.js"))
+
+	templ := template.Must(router.newTemplate("index").Parse(`<script src="{{ asset "app.js" }}"></script>`))
+	router.SetHTMLTemplate(templ)
+	router.Get("/", func(c *Context) {
+		c.HTML(http.StatusOK, "index", nil)
+	})
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	_, body := getStatic(t, server.URL+"/", nil)
+	assert.Equal(t, `<script src="`+appJS+`"></script>`, body)
+
+	cases := []struct {
+		path         string
+		statusCode   int
+		body         string
+		cacheControl string
+	}{
+		{appJS, http.StatusOK, "alert(1)", "public, max-age=31536000, immutable"},
+		{appCSS, http.StatusOK, "body {}", "public, max-age=31536000, immutable"},
+		{license, http.StatusOK, "MIT", "public, max-age=31536000, immutable"},
+		{"/site/static/app.js", http.StatusOK, "alert(1)", "public, max-age=60"},
+		{"/site/static/app.00000000.js", http.StatusNotFound, default404Body, ""},
+		{"/site/static/app." + strings.ToUpper(hash("alert(1)")) + ".js", http.StatusNotFound, default404Body, ""},
+		{"/site/static/.env." + hash("SECRET=1"), http.StatusNotFound, default404Body, ""},
+	}
+	for _, tc := range cases {
+		resp, body := getStatic(t, server.URL+tc.path, http.Header{"Accept-Encoding": {"identity"}})
+		assert.Equal(t, tc.statusCode, resp.StatusCode, tc.path)
+		assert.Equal(t, tc.body, body, tc.path)
+		assert.Equal(t, tc.cacheControl, resp.Header.Get("Cache-Control"), tc.path)
+	}
+
+	resp, body := getStatic(t, server.URL+appJS, http.Header{"Accept-Encoding": {"gzip"}})
+	assert.Equal(t, "gzip", body)
+	assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+	assert.Equal(t, "public, max-age=31536000, immutable", resp.Header.Get("Cache-Control"))
+
+	// the fingerprint changes with the content
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "app.js"), []byte("alert(2)"), 0644))
+	assert.Nil(t, os.Chtimes(filepath.Join(dir, "app.js"), time.Now(), time.Now().Add(time.Second)))
+	assert.Equal(t, "/site/static/app."+hash("alert(2)")+".js", router.Asset("app.js"))
+	resp, _ = getStatic(t, server.URL+appJS, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestParseAcceptEncoding(t *testing.T) {
+	codings := parseAcceptEncoding("gzip;q=0.8, BR, identity;q=0, deflate;q=x")
+	assert.Equal(t, 0.8, acceptEncodingQuality(codings, "gzip"))
+	assert.Equal(t, 1.0, acceptEncodingQuality(codings, "br"))
+	assert.Equal(t, 0.0, acceptEncodingQuality(codings, "identity"))
+	assert.Equal(t, 0.0, acceptEncodingQuality(codings, "deflate"))
+	assert.Equal(t, 0.0, acceptEncodingQuality(codings, "zstd"))
+	assert.Equal(t, 0.5, acceptEncodingQuality(parseAcceptEncoding("*;q=0.5"), "zstd"))
+}
+
+func TestStaticFallback(t *testing.T) {
+	dir := writeStaticFiles(t, map[string]string{
+		"index.html":      "<div id=root></div>",
+		"app.js":          "alert(1)",
+		"docs/index.html": "<p>docs</p>",
+		".env":            "SECRET=1",
+	})
+	jsonNoRoute := func(c *Context) {
+		c.JSON(http.StatusNotFound, JSON{"error": "not found"})
+	}
+
+	t.Run("root", func(t *testing.T) {
+		router := New()
+		// NoRoute handlers set before or after Static both run after the application
+		router.NoRoute(func(c *Context) {
+			c.String(http.StatusTeapot, "replaced")
+		})
+		router.Get("/api/users", func(c *Context) {
+			c.JSON(http.StatusOK, JSON{"users": []string{}})
+		})
+		router.Static("/", dir, StaticOptions{
+			Fallback:        "index.html",
+			FallbackExclude: []string{"/api/"},
+			MaxAge:          map[string]time.Duration{".html": 0},
+		})
+		router.Post("/api/users", func(c *Context) {})
+		router.NoRoute(jsonNoRoute)
+
+		server := httptest.NewServer(router)
+		defer server.Close()
+
+		cases := []struct {
+			method     string
+			path       string
+			statusCode int
+			body       string
+		}{
+			{http.MethodGet, "/", http.StatusOK, "<div id=root></div>"},
+			{http.MethodGet, "/dashboard/settings", http.StatusOK, "<div id=root></div>"},
+			{http.MethodGet, "/dashboard/settings?tab=1", http.StatusOK, "<div id=root></div>"},
+			{http.MethodGet, "/app.js", http.StatusOK, "alert(1)"},
+			{http.MethodGet, "/docs/", http.StatusOK, "<p>docs</p>"},
+			{http.MethodGet, "/api/users", http.StatusOK, `{"users":[]}`},
+			{http.MethodGet, "/api/missing", http.StatusNotFound, `{"error":"not found"}`},
+			{http.MethodGet, "/api", http.StatusNotFound, `{"error":"not found"}`},
+			{http.MethodGet, "/apiary", http.StatusOK, "<div id=root></div>"},
+			{http.MethodGet, "/missing.js", http.StatusNotFound, `{"error":"not found"}`},
+			{http.MethodGet, "/.env", http.StatusNotFound, `{"error":"not found"}`},
+			{http.MethodPost, "/dashboard", http.StatusNotFound, `{"error":"not found"}`},
+		}
+		for _, tc := range cases {
+			req, err := http.NewRequest(tc.method, server.URL+tc.path, nil)
+			assert.Nil(t, err)
+			resp, err := http.DefaultClient.Do(req)
+			assert.Nil(t, err)
+			bodyBytes, err := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			assert.Nil(t, err)
+
+			assert.Equal(t, tc.statusCode, resp.StatusCode, tc.path)
+			assert.Equal(t, tc.body, strings.TrimSpace(string(bodyBytes)), tc.path)
+			if tc.body == "<div id=root></div>" {
+				assert.Equal(t, "no-cache", resp.Header.Get("Cache-Control"), tc.path)
+			}
+		}
+
+		resp, _ := getStatic(t, server.URL+"/docs", nil)
+		assert.Equal(t, http.StatusMovedPermanently, resp.StatusCode)
+		assert.Equal(t, "docs/", resp.Header.Get("Location"))
+	})
+
+	t.Run("post only", func(t *testing.T) {
+		router := New()
+		router.Post("/api/users", func(c *Context) {})
+		router.Static("/", dir, StaticOptions{
+			Fallback:        "index.html",
+			FallbackExclude: []string{"/api/"},
+		})
+		router.NoRoute(jsonNoRoute)
+
+		server := httptest.NewServer(router)
+		defer server.Close()
+
+		for _, path := range []string{"/", "/dashboard/settings", "/app.js"} {
+			resp, body := getStatic(t, server.URL+path, nil)
+			assert.Equal(t, http.StatusOK, resp.StatusCode, path)
+			assert.NotEmpty(t, body, path)
+		}
+		resp, body := getStatic(t, server.URL+"/api/x", nil)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		assert.Equal(t, `{"error":"not found"}`, strings.TrimSpace(body))
+
+		req, err := http.NewRequest(http.MethodHead, server.URL+"/dashboard", nil)
+		assert.Nil(t, err)
+		resp, err = http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		req, err = http.NewRequest(http.MethodPut, server.URL+"/dashboard", nil)
+		assert.Nil(t, err)
+		resp, err = http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	})
+
+	t.Run("prefix", func(t *testing.T) {
+		router := New()
+		router.NoRoute(jsonNoRoute)
+		router.Prefix("/app").Static("/", dir, StaticOptions{
+			Fallback:        "index.html",
+			FallbackExclude: []string{"/app/api"},
+		})
+		router.Static("/plain", dir)
+
+		server := httptest.NewServer(router)
+		defer server.Close()
+
+		cases := []struct {
+			path       string
+			statusCode int
+			body       string
+		}{
+			{"/app/", http.StatusOK, "<div id=root></div>"},
+			{"/app/dashboard/settings", http.StatusOK, "<div id=root></div>"},
+			{"/app/app.js", http.StatusOK, "alert(1)"},
+			{"/app/missing.js", http.StatusNotFound, default404Body},
+			{"/app/api/users", http.StatusNotFound, default404Body},
+			{"/app/.env", http.StatusNotFound, default404Body},
+			{"/plain/dashboard", http.StatusNotFound, default404Body},
+			{"/other", http.StatusNotFound, `{"error":"not found"}` + "\n"},
+		}
+		for _, tc := range cases {
+			resp, body := getStatic(t, server.URL+tc.path, nil)
+			assert.Equal(t, tc.statusCode, resp.StatusCode, tc.path)
+			assert.Equal(t, tc.body, body, tc.path)
+		}
+	})
+}
@@ -0,0 +1,181 @@
+package looli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+)
+
+var ndjsonContentType = []string{"application/x-ndjson"}
+
+// Iterator returns the next item of a stream, ok is false when there are no more items.
+type Iterator func() (item interface{}, ok bool)
+
+// Stream calls step until it returns false or the client disconnects, the response is flushed
+// after each step. It returns true if the client disconnected in the middle of the stream.
+//
+//	c.Stream(func(w io.Writer) bool {
+//		if msg, ok := <-messages; ok {
+//			fmt.Fprintln(w, msg)
+//			return true
+//		}
+//		return false
+//	})
+func (c *Context) Stream(step func(w io.Writer) bool) bool {
+	done := c.Request.Context().Done()
+	for {
+		select {
+		case <-done:
+			return true
+		default:
+			keepOpen := step(c.ResponseWriter)
+			c.Flush()
+			if !keepOpen {
+				return false
+			}
+		}
+	}
+}
+
+// Flush sends any buffered data to the client.
+func (c *Context) Flush() {
+	if flusher, ok := c.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// StreamNDJSON writes status code and items as newline delimited JSON, one item per line.
+// items can be a channel, a slice or an Iterator, each item is flushed as soon as it is
+// written, so the whole result is never buffered. It stops when the client disconnects and
+// returns the error of the request context in that case. An error is returned without writing
+// anything if items can't be streamed.
+func (c *Context) StreamNDJSON(code int, items interface{}) error {
+	iterate, err := iterator(items)
+	if err != nil {
+		return err
+	}
+
+	setContentType(c.ResponseWriter, ndjsonContentType)
+	c.Status(code)
+
+	encoder := json.NewEncoder(c.ResponseWriter)
+	return iterate(c.Request.Context(), func(item interface{}) error {
+		if err := encoder.Encode(item); err != nil {
+			return err
+		}
+		c.Flush()
+		return nil
+	})
+}
+
+// StreamJSONArray writes status code and items as a JSON array, items can be a channel,
+// a slice or an Iterator, each item is flushed as soon as it is written. It stops when the
+// client disconnects and returns the error of the request context in that case, the array
+// is not closed then. An error is returned without writing anything if items can't be streamed.
+func (c *Context) StreamJSONArray(code int, items interface{}) error {
+	iterate, err := iterator(items)
+	if err != nil {
+		return err
+	}
+
+	setContentType(c.ResponseWriter, jsonContentType)
+	c.Status(code)
+
+	if _, err := io.WriteString(c.ResponseWriter, "["); err != nil {
+		return err
+	}
+
+	first := true
+	err = iterate(c.Request.Context(), func(item interface{}) error {
+		data, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		if !first {
+			data = append([]byte{','}, data...)
+		}
+		first = false
+
+		if _, err := c.ResponseWriter.Write(data); err != nil {
+			return err
+		}
+		c.Flush()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(c.ResponseWriter, "]\n")
+	return err
+}
+
+// iterator returns the function which calls fn with each item of items until items are
+// exhausted, fn returns error or ctx is done. It returns an error if items is not a channel,
+// a slice or an Iterator.
+func iterator(items interface{}) (func(ctx context.Context, fn func(interface{}) error) error, error) {
+	var next Iterator
+	switch it := items.(type) {
+	case Iterator:
+		next = it
+	case func() (interface{}, bool):
+		next = it
+	}
+
+	if next != nil {
+		return func(ctx context.Context, fn func(interface{}) error) error {
+			for {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				item, ok := next()
+				if !ok {
+					return nil
+				}
+				if err := fn(item); err != nil {
+					return err
+				}
+			}
+		}, nil
+	}
+
+	val := reflect.ValueOf(items)
+	switch {
+	case val.Kind() == reflect.Chan && val.Type().ChanDir()&reflect.RecvDir != 0:
+		return func(ctx context.Context, fn func(interface{}) error) error {
+			cases := []reflect.SelectCase{
+				{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+				{Dir: reflect.SelectRecv, Chan: val},
+			}
+			for {
+				chosen, item, ok := reflect.Select(cases)
+				if chosen == 0 {
+					return ctx.Err()
+				}
+				if !ok {
+					return nil
+				}
+				if err := fn(item.Interface()); err != nil {
+					return err
+				}
+			}
+		}, nil
+	case val.Kind() == reflect.Slice || val.Kind() == reflect.Array:
+		return func(ctx context.Context, fn func(interface{}) error) error {
+			for i := 0; i < val.Len(); i++ {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				if err := fn(val.Index(i).Interface()); err != nil {
+					return err
+				}
+			}
+			return nil
+		}, nil
+	default:
+		return nil, fmt.Errorf("can not stream %T, items must be a channel, slice or Iterator", items)
+	}
+}
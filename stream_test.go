@@ -0,0 +1,148 @@
+package looli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStream(t *testing.T) {
+	t.Run("stop by step", func(t *testing.T) {
+		router := New()
+		router.Get("/", func(c *Context) {
+			count := 0
+			disconnected := c.Stream(func(w io.Writer) bool {
+				count++
+				fmt.Fprintf(w, "%d\n", count)
+				return count < 3
+			})
+			assert.False(t, disconnected)
+		})
+
+		server := httptest.NewServer(router)
+		defer server.Close()
+
+		resp, err := http.Get(server.URL)
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		bodyBytes, err := ioutil.ReadAll(resp.Body)
+		assert.Nil(t, err)
+		assert.Equal(t, "1\n2\n3\n", string(bodyBytes))
+	})
+
+	t.Run("client disconnect", func(t *testing.T) {
+		result := make(chan bool, 1)
+		router := New()
+		router.Get("/", func(c *Context) {
+			result <- c.Stream(func(w io.Writer) bool {
+				fmt.Fprintln(w, "tick")
+				time.Sleep(10 * time.Millisecond)
+				return true
+			})
+		})
+
+		server := httptest.NewServer(router)
+		defer server.Close()
+
+		resp, err := http.Get(server.URL)
+		assert.Nil(t, err)
+		line, err := bufio.NewReader(resp.Body).ReadString('\n')
+		assert.Nil(t, err)
+		assert.Equal(t, "tick\n", line)
+		resp.Body.Close()
+
+		select {
+		case disconnected := <-result:
+			assert.True(t, disconnected)
+		case <-time.After(5 * time.Second):
+			t.Fatal("stream is not stopped after client disconnected")
+		}
+	})
+}
+
+func TestStreamJSON(t *testing.T) {
+	type item struct {
+		ID int `json:"id"`
+	}
+
+	newItems := func() chan item {
+		items := make(chan item, 3)
+		for i := 1; i <= 3; i++ {
+			items <- item{ID: i}
+		}
+		close(items)
+		return items
+	}
+
+	count := 0
+	iterator := Iterator(func() (interface{}, bool) {
+		count++
+		return item{ID: count}, count <= 2
+	})
+
+	router := New()
+	router.Get("/ndjson", func(c *Context) {
+		assert.Nil(t, c.StreamNDJSON(http.StatusOK, newItems()))
+	})
+	router.Get("/array", func(c *Context) {
+		assert.Nil(t, c.StreamJSONArray(http.StatusOK, newItems()))
+	})
+	router.Get("/iterator", func(c *Context) {
+		assert.Nil(t, c.StreamJSONArray(http.StatusOK, iterator))
+	})
+	router.Get("/slice", func(c *Context) {
+		assert.Nil(t, c.StreamNDJSON(http.StatusOK, []item{{ID: 1}}))
+	})
+	router.Get("/empty", func(c *Context) {
+		assert.Nil(t, c.StreamJSONArray(http.StatusOK, []item{}))
+	})
+	// the error can still be responded when items can't be streamed
+	router.Get("/invalid", func(c *Context) {
+		err := c.StreamNDJSON(http.StatusOK, 1)
+		assert.NotNil(t, err)
+		assert.False(t, c.Written())
+		c.String(http.StatusInternalServerError, err.Error())
+	})
+	router.Get("/invalid-array", func(c *Context) {
+		err := c.StreamJSONArray(http.StatusOK, make(chan<- item))
+		assert.NotNil(t, err)
+		assert.False(t, c.Written())
+		c.String(http.StatusInternalServerError, err.Error())
+	})
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	cases := []struct {
+		path        string
+		statusCode  int
+		contentType string
+		body        string
+	}{
+		{"/ndjson", http.StatusOK, ndjsonContentType[0], "{\"id\":1}\n{\"id\":2}\n{\"id\":3}\n"},
+		{"/array", http.StatusOK, jsonContentType[0], "[{\"id\":1},{\"id\":2},{\"id\":3}]\n"},
+		{"/iterator", http.StatusOK, jsonContentType[0], "[{\"id\":1},{\"id\":2}]\n"},
+		{"/slice", http.StatusOK, ndjsonContentType[0], "{\"id\":1}\n"},
+		{"/empty", http.StatusOK, jsonContentType[0], "[]\n"},
+		{"/invalid", http.StatusInternalServerError, plainContentType[0], "can not stream int, items must be a channel, slice or Iterator"},
+		{"/invalid-array", http.StatusInternalServerError, plainContentType[0], "can not stream chan<- looli.item, items must be a channel, slice or Iterator"},
+	}
+
+	for _, tc := range cases {
+		resp, err := http.Get(server.URL + tc.path)
+		assert.Nil(t, err)
+		bodyBytes, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Nil(t, err)
+		assert.Equal(t, tc.statusCode, resp.StatusCode, tc.path)
+		assert.Equal(t, tc.contentType, resp.Header.Get("Content-Type"), tc.path)
+		assert.Equal(t, tc.body, string(bodyBytes), tc.path)
+	}
+}
@@ -0,0 +1,429 @@
+package looli
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// HTMLRenderer returns the Render of the template name with data, it is used by Context.HTML
+// and Context.Negotiate when it is set.
+type HTMLRenderer interface {
+	Instance(name string, data interface{}) Render
+}
+
+// TemplateOptions describe the templates parsed by ParseHTMLTemplates.
+type TemplateOptions struct {
+	// Dir is the root directory of templates, the names of templates are their slash
+	// separated paths relative to Dir, such as "users/show.tmpl".
+	Dir string
+
+	// FS is the file system of templates such as embed.FS, it takes precedence over Dir.
+	FS fs.FS
+
+	// Layout is the template executed for every page, such as "layouts/base.tmpl", it calls
+	// the blocks defined by the page. If it is empty pages are executed without layout.
+	Layout string
+
+	// Partials are glob patterns of the templates shared by all pages, such as
+	// "partials/*.tmpl", they are called with {{ template "partials/header.tmpl" . }}.
+	Partials []string
+
+	// Pages are glob patterns of the pages, each page is parsed with the layout and partials
+	// into its own template set, so blocks with the same name in different pages don't collide.
+	Pages []string
+
+	// FuncMap is added to the templates before they are parsed.
+	FuncMap template.FuncMap
+
+	// LeftDelim and RightDelim are the action delimiters, default are "{{" and "}}".
+	LeftDelim  string
+	RightDelim string
+}
+
+// HTMLTemplates holds a template set for each page, it is created by ParseHTMLTemplates.
+type HTMLTemplates struct {
+	// template set of each page keyed by page name
+	pages map[string]*template.Template
+
+	// name of the template executed for pages
+	layout string
+}
+
+// ParseHTMLTemplates parses the pages with the layout and partials described by options.
+//
+//	templates/
+//		layouts/base.tmpl    <html>{{ block "content" . }}{{ end }}</html>
+//		partials/nav.tmpl    <nav>...</nav>
+//		index.tmpl           {{ define "content" }}{{ template "partials/nav.tmpl" . }}...{{ end }}
+//		users/show.tmpl      {{ define "content" }}...{{ end }}
+//
+//	templates, err := looli.ParseHTMLTemplates(looli.TemplateOptions{
+//		Dir:      "templates",
+//		Layout:   "layouts/base.tmpl",
+//		Partials: []string{"partials/*.tmpl"},
+//		Pages:    []string{"*.tmpl", "users/*.tmpl"},
+//	})
+func ParseHTMLTemplates(options TemplateOptions) (*HTMLTemplates, error) {
+	return parseHTMLTemplates(options.fileSystem(), options)
+}
+
+// fileSystem returns FS, or the directory Dir if FS is nil.
+func (options TemplateOptions) fileSystem() fs.FS {
+	if options.FS != nil {
+		return options.FS
+	}
+	return os.DirFS(options.Dir)
+}
+
+// parseHTMLTemplates parses the templates of options in fsys.
+func parseHTMLTemplates(fsys fs.FS, options TemplateOptions) (*HTMLTemplates, error) {
+	base := template.New("").Funcs(options.FuncMap).Delims(options.LeftDelim, options.RightDelim)
+
+	if options.Layout != "" {
+		if err := parseTemplateFiles(fsys, base, options.Layout); err != nil {
+			return nil, err
+		}
+	}
+
+	partials, err := globTemplateFiles(fsys, options.Partials)
+	if err != nil {
+		return nil, err
+	}
+	if err := parseTemplateFiles(fsys, base, partials...); err != nil {
+		return nil, err
+	}
+
+	pages, err := globTemplateFiles(fsys, options.Pages)
+	if err != nil {
+		return nil, err
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("html/template: pages %v match no files", options.Pages)
+	}
+
+	templates := &HTMLTemplates{
+		pages:  make(map[string]*template.Template, len(pages)),
+		layout: options.Layout,
+	}
+	for _, page := range pages {
+		if page == options.Layout {
+			continue
+		}
+
+		set, err := base.Clone()
+		if err != nil {
+			return nil, err
+		}
+		if err := parseTemplateFiles(fsys, set, page); err != nil {
+			return nil, err
+		}
+		templates.pages[page] = set
+	}
+	return templates, nil
+}
+
+// globTemplateFiles returns the sorted files matching patterns without duplication.
+func globTemplateFiles(fsys fs.FS, patterns []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var files []string
+	for _, pattern := range patterns {
+		matches, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			if !seen[match] {
+				seen[match] = true
+				files = append(files, match)
+			}
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// parseTemplateFiles parses files into t, the templates are named by their paths in fsys.
+func parseTemplateFiles(fsys fs.FS, t *template.Template, files ...string) error {
+	for _, file := range files {
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return err
+		}
+		if _, err := t.New(file).Parse(string(content)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Instance returns the Render of the page name, it executes the layout if there is one.
+func (templates *HTMLTemplates) Instance(name string, data interface{}) Render {
+	set, ok := templates.pages[name]
+	if !ok {
+		return errorRender{fmt.Errorf("html/template: page %q is undefined", name)}
+	}
+
+	entry := name
+	if templates.layout != "" {
+		entry = templates.layout
+	}
+	return HTMLRender{Template: set, Name: entry, Data: data}
+}
+
+// Pages returns the names of pages in order.
+func (templates *HTMLTemplates) Pages() []string {
+	pages := make([]string, 0, len(templates.pages))
+	for page := range templates.pages {
+		pages = append(pages, page)
+	}
+	sort.Strings(pages)
+	return pages
+}
+
+// errorRender fails to render with err.
+type errorRender struct {
+	err error
+}
+
+func (r errorRender) Render(http.ResponseWriter) error {
+	return r.err
+}
+
+func (r errorRender) WriteContentType(rw http.ResponseWriter) {
+	setContentType(rw, htmlContentType)
+}
+
+// SetFuncMap sets the FuncMap used by LoadHTMLGlob, LoadHTMLFiles and LoadHTMLTemplates, it
+// must be called before loading templates.
+func (engine *Engine) SetFuncMap(funcMap template.FuncMap) {
+	engine.funcMap = funcMap
+}
+
+// Delims sets the action delimiters used by LoadHTMLGlob, LoadHTMLFiles and LoadHTMLTemplates,
+// it must be called before loading templates.
+func (engine *Engine) Delims(left, right string) {
+	engine.delims = [2]string{left, right}
+}
+
+// newTemplate returns an empty template named name with the FuncMap and delimiters of engine.
+func (engine *Engine) newTemplate(name string) *template.Template {
+	return template.New(name).Funcs(engine.templateFuncs()).Delims(engine.delims[0], engine.delims[1])
+}
+
+// templateFuncs returns the functions available in templates, the builtin "asset" can be
+// overridden by the FuncMap of engine.
+func (engine *Engine) templateFuncs() template.FuncMap {
+	funcMap := template.FuncMap{"asset": engine.Asset}
+	for name, fn := range engine.funcMap {
+		funcMap[name] = fn
+	}
+	return funcMap
+}
+
+// LoadHTMLGlob parses the templates matching pattern as Template, the templates are named
+// by their base names. In debug mode they are reloaded when the files change.
+func (engine *Engine) LoadHTMLGlob(pattern string) {
+	files := func() ([]string, error) {
+		files, err := filepath.Glob(pattern)
+		if err == nil && len(files) == 0 {
+			err = fmt.Errorf("html/template: pattern matches no files: %#q", pattern)
+		}
+		return files, err
+	}
+
+	if engine.Debug {
+		engine.loadHTMLReloader(nil, files, func(files []string) (HTMLRenderer, error) {
+			templ, err := engine.parseHTMLFiles(files)
+			return templateRenderer{templ}, err
+		})
+		return
+	}
+
+	matches, err := files()
+	if err != nil {
+		panic(err)
+	}
+	engine.LoadHTMLFiles(matches...)
+}
+
+// LoadHTMLFiles parses files as Template, the templates are named by their base names. In
+// debug mode they are reloaded when the files change.
+func (engine *Engine) LoadHTMLFiles(files ...string) {
+	if len(files) == 0 {
+		panic("html/template: no files named in call to LoadHTMLFiles")
+	}
+
+	if engine.Debug {
+		engine.loadHTMLReloader(nil, func() ([]string, error) {
+			return files, nil
+		}, func(files []string) (HTMLRenderer, error) {
+			templ, err := engine.parseHTMLFiles(files)
+			return templateRenderer{templ}, err
+		})
+		return
+	}
+
+	engine.Template = template.Must(engine.parseHTMLFiles(files))
+	engine.HTMLRenderer = nil
+}
+
+func (engine *Engine) parseHTMLFiles(files []string) (*template.Template, error) {
+	return engine.newTemplate(filepath.Base(files[0])).ParseFiles(files...)
+}
+
+// LoadHTMLFS parses the templates in fsys matching patterns as Template, such as embed.FS,
+// the templates are named by their base names. In debug mode they are reloaded when the
+// files change.
+//
+//	//go:embed templates
+//	var templates embed.FS
+//
+//	router.LoadHTMLFS(templates, "templates/*.tmpl")
+func (engine *Engine) LoadHTMLFS(fsys fs.FS, patterns ...string) {
+	files := func() ([]string, error) {
+		files, err := globTemplateFiles(fsys, patterns)
+		if err == nil && len(files) == 0 {
+			err = fmt.Errorf("html/template: patterns match no files: %#q", patterns)
+		}
+		return files, err
+	}
+	parse := func(files []string) (*template.Template, error) {
+		return engine.newTemplate(path.Base(files[0])).ParseFS(fsys, files...)
+	}
+
+	if engine.Debug {
+		engine.loadHTMLReloader(fsys, files, func(files []string) (HTMLRenderer, error) {
+			templ, err := parse(files)
+			return templateRenderer{templ}, err
+		})
+		return
+	}
+
+	matches, err := files()
+	if err != nil {
+		panic(err)
+	}
+	engine.Template = template.Must(parse(matches))
+	engine.HTMLRenderer = nil
+}
+
+// LoadHTMLTemplates parses the pages with layout and partials described by options and uses
+// them for Context.HTML, see ParseHTMLTemplates. The FuncMap and delimiters of engine are used
+// if options don't set them. In debug mode the templates are reloaded when the files change.
+func (engine *Engine) LoadHTMLTemplates(options TemplateOptions) {
+	engine.HTMLRenderer = engine.parseHTMLTemplates(options)
+}
+
+// parseHTMLTemplates parses the templates of options with the FuncMap and delimiters of
+// engine, they are reloaded when the files change in debug mode.
+func (engine *Engine) parseHTMLTemplates(options TemplateOptions) HTMLRenderer {
+	options = engine.templateOptions(options)
+	if engine.Debug {
+		fsys := options.fileSystem()
+		return newReloadRenderer(fsys, func() ([]string, error) {
+			return options.files(fsys)
+		}, func([]string) (HTMLRenderer, error) {
+			return ParseHTMLTemplates(options)
+		})
+	}
+
+	return mustHTMLTemplates(ParseHTMLTemplates(options))
+}
+
+// SetHTMLRenderer sets the HTMLRenderer used by Context.HTML for the routes of the prefix,
+// the routes of other prefixes are not affected. Routes without a renderer of their prefix
+// use the renderer or Template of engine.
+//
+//	admin := router.Prefix("/admin")
+//	admin.LoadHTMLTemplates(looli.TemplateOptions{Dir: "admin/templates", Pages: []string{"*.tmpl"}})
+func (p *RouterPrefix) SetHTMLRenderer(renderer HTMLRenderer) {
+	if !p.isPrefix {
+		p.engine.HTMLRenderer = renderer
+		return
+	}
+	p.htmlRenderer = renderer
+}
+
+// SetHTMLTemplate sets the template used by Context.HTML for the routes of the prefix.
+func (p *RouterPrefix) SetHTMLTemplate(templ *template.Template) {
+	if !p.isPrefix {
+		p.engine.Template = templ
+		p.engine.HTMLRenderer = nil
+		return
+	}
+	p.htmlRenderer = templateRenderer{templ}
+}
+
+// LoadHTMLTemplates parses the templates described by options for the routes of the prefix,
+// see Engine.LoadHTMLTemplates.
+func (p *RouterPrefix) LoadHTMLTemplates(options TemplateOptions) {
+	p.SetHTMLRenderer(p.engine.parseHTMLTemplates(options))
+}
+
+// applyHTMLRenderer is the first handler of the routes of prefix, it sets the HTMLRenderer
+// of the prefix to the Context.
+func (p *RouterPrefix) applyHTMLRenderer(c *Context) {
+	if p.htmlRenderer != nil {
+		c.htmlRenderer = p.htmlRenderer
+	}
+}
+
+// files returns the paths of the layout, partials and pages of options in fsys.
+func (options TemplateOptions) files(fsys fs.FS) ([]string, error) {
+	patterns := append(append([]string(nil), options.Partials...), options.Pages...)
+	files, err := globTemplateFiles(fsys, patterns)
+	if err != nil {
+		return nil, err
+	}
+	if options.Layout != "" {
+		files = append(files, options.Layout)
+	}
+	return files, nil
+}
+
+// templateOptions fills options with the FuncMap and delimiters of engine.
+func (engine *Engine) templateOptions(options TemplateOptions) TemplateOptions {
+	funcMap := engine.templateFuncs()
+	for name, fn := range options.FuncMap {
+		funcMap[name] = fn
+	}
+	options.FuncMap = funcMap
+	if options.LeftDelim == "" && options.RightDelim == "" {
+		options.LeftDelim, options.RightDelim = engine.delims[0], engine.delims[1]
+	}
+	return options
+}
+
+func mustHTMLTemplates(templates *HTMLTemplates, err error) *HTMLTemplates {
+	if err != nil {
+		panic(err)
+	}
+	return templates
+}
+
+// statusRender is a Render which decides the status code it is written with, such as the
+// error page of templates in debug mode.
+type statusRender interface {
+	Render
+	statusCode() int
+}
+
+// htmlRender returns the Render of template name with data and the status code to write it
+// with, which is code unless the Render decides it.
+func (c *Context) htmlRender(code int, name string, data interface{}) (Render, int) {
+	if c.htmlRenderer == nil {
+		return HTMLRender{Template: c.template, Name: name, Data: data}, code
+	}
+
+	r := c.htmlRenderer.Instance(name, data)
+	if sr, ok := r.(statusRender); ok {
+		code = sr.statusCode()
+	}
+	return r, code
+}
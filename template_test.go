@@ -0,0 +1,208 @@
+package looli
+
+import (
+	"embed"
+	"html/template"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//go:embed test/views
+var testViewsFS embed.FS
+
+var testFuncMap = template.FuncMap{
+	"upper": strings.ToUpper,
+}
+
+func TestParseHTMLTemplates(t *testing.T) {
+	templates, err := ParseHTMLTemplates(TemplateOptions{
+		Dir:      "test/views",
+		Layout:   "layouts/base.tmpl",
+		Partials: []string{"partials/*.tmpl"},
+		Pages:    []string{"*.tmpl", "users/*.tmpl"},
+		FuncMap:  testFuncMap,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"index.tmpl", "users/show.tmpl"}, templates.Pages())
+
+	_, err = ParseHTMLTemplates(TemplateOptions{
+		Dir:   "test/views",
+		Pages: []string{"*.html"},
+	})
+	assert.NotNil(t, err)
+
+	// partials use function upper which is not defined
+	_, err = ParseHTMLTemplates(TemplateOptions{
+		Dir:      "test/views",
+		Partials: []string{"partials/*.tmpl"},
+		Pages:    []string{"*.tmpl"},
+	})
+	assert.NotNil(t, err)
+}
+
+func TestLoadHTMLTemplates(t *testing.T) {
+	router := New()
+	router.SetFuncMap(testFuncMap)
+	router.LoadHTMLTemplates(TemplateOptions{
+		Dir:      "test/views",
+		Layout:   "layouts/base.tmpl",
+		Partials: []string{"partials/*.tmpl"},
+		Pages:    []string{"*.tmpl", "users/*.tmpl"},
+	})
+	router.Get("/", func(c *Context) {
+		c.HTML(http.StatusOK, "index.tmpl", JSON{"site": "looli", "title": "Posts"})
+	})
+	router.Get("/user", func(c *Context) {
+		c.HTML(http.StatusOK, "users/show.tmpl", JSON{"site": "looli", "name": "cssivision"})
+	})
+	router.Get("/missing", func(c *Context) {
+		assert.Panics(t, func() {
+			c.HTML(http.StatusOK, "missing.tmpl", nil)
+		})
+	})
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	cases := []struct {
+		path       string
+		statusCode int
+		body       string
+	}{
+		{"/", http.StatusOK, "<html><title>Home</title><body><nav>LOOLI</nav><h1>Posts</h1></body></html>"},
+		{"/user", http.StatusOK, "<html><title>User</title><body><nav>LOOLI</nav><p>cssivision</p></body></html>"},
+		{"/missing", http.StatusOK, ""},
+	}
+
+	for _, tc := range cases {
+		resp, err := http.Get(server.URL + tc.path)
+		assert.Nil(t, err)
+		bodyBytes, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Nil(t, err)
+		assert.Equal(t, tc.statusCode, resp.StatusCode, tc.path)
+		assert.Equal(t, htmlContentType[0], resp.Header.Get("Content-Type"))
+		assert.Equal(t, tc.body, string(bodyBytes), tc.path)
+	}
+}
+
+func TestTemplateDelims(t *testing.T) {
+	router := New()
+	router.SetFuncMap(testFuncMap)
+	router.Delims("[[", "]]")
+	router.LoadHTMLGlob("test/delims/*")
+	router.Get("/", func(c *Context) {
+		c.HTML(http.StatusOK, "index.tmpl", JSON{"title": "posts"})
+	})
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	assert.Nil(t, err)
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Nil(t, err)
+	assert.Equal(t, "<h1>POSTS</h1>{{ .title }}", string(bodyBytes))
+}
+
+func TestLoadHTMLFS(t *testing.T) {
+	router := New()
+	router.LoadHTMLFS(testFS, "test/templates/*.tmpl")
+	router.Get("/", func(c *Context) {
+		c.HTML(http.StatusOK, "index.tmpl", JSON{"title": "Posts"})
+	})
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	assert.Nil(t, err)
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(bodyBytes), "Posts")
+
+	assert.Panics(t, func() {
+		router.LoadHTMLFS(testFS, "test/*.tmpl")
+	})
+}
+
+func TestLoadHTMLTemplatesFS(t *testing.T) {
+	sub, err := fs.Sub(testViewsFS, "test/views")
+	assert.Nil(t, err)
+
+	templates, err := ParseHTMLTemplates(TemplateOptions{
+		FS:       sub,
+		Layout:   "layouts/base.tmpl",
+		Partials: []string{"partials/*.tmpl"},
+		Pages:    []string{"*.tmpl", "users/*.tmpl"},
+		FuncMap:  testFuncMap,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"index.tmpl", "users/show.tmpl"}, templates.Pages())
+}
+
+func TestPrefixTemplates(t *testing.T) {
+	router := New()
+	router.SetFuncMap(testFuncMap)
+	router.LoadHTMLGlob("test/templates/*")
+
+	admin := router.Prefix("/admin")
+	admin.LoadHTMLTemplates(TemplateOptions{
+		Dir:      "test/views",
+		Layout:   "layouts/base.tmpl",
+		Partials: []string{"partials/*.tmpl"},
+		Pages:    []string{"*.tmpl"},
+	})
+	admin.Use(func(c *Context) {
+		if c.Query("denied") != "" {
+			c.Abort()
+			c.HTML(http.StatusForbidden, "index.tmpl", JSON{"site": "admin", "title": "Denied"})
+		}
+	})
+	admin.Get("/", func(c *Context) {
+		c.HTML(http.StatusOK, "index.tmpl", JSON{"site": "admin", "title": "Dashboard"})
+	})
+
+	delims := router.Prefix("/delims")
+	delims.SetHTMLTemplate(template.Must(template.New("").Delims("[[", "]]").Funcs(testFuncMap).ParseFiles("test/delims/index.tmpl")))
+	delims.Get("/", func(c *Context) {
+		c.HTML(http.StatusOK, "index.tmpl", JSON{"title": "posts"})
+	})
+
+	router.Get("/", func(c *Context) {
+		c.HTML(http.StatusOK, "index.tmpl", JSON{"title": "Posts"})
+	})
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	cases := []struct {
+		path       string
+		statusCode int
+		body       string
+	}{
+		{"/admin/", http.StatusOK, "<html><title>Home</title><body><nav>ADMIN</nav><h1>Dashboard</h1></body></html>"},
+		{"/admin/?denied=1", http.StatusForbidden, "<html><title>Home</title><body><nav>ADMIN</nav><h1>Denied</h1></body></html>"},
+		{"/delims/", http.StatusOK, "<h1>POSTS</h1>{{ .title }}"},
+		{"/", http.StatusOK, "<html>\n    <h1>\n        Posts\n    </h1>\n</html>"},
+	}
+
+	for _, tc := range cases {
+		resp, err := http.Get(server.URL + tc.path)
+		assert.Nil(t, err)
+		bodyBytes, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Nil(t, err)
+		assert.Equal(t, tc.statusCode, resp.StatusCode, tc.path)
+		assert.Equal(t, tc.body, string(bodyBytes), tc.path)
+	}
+}
@@ -0,0 +1,727 @@
+package looli
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// message types of WebSocket, defined in RFC 6455 section 11.8
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+
+	continuationFrame = 0
+)
+
+// close codes of WebSocket, defined in RFC 6455 section 11.7
+const (
+	CloseNormalClosure           = 1000
+	CloseGoingAway               = 1001
+	CloseProtocolError           = 1002
+	CloseUnsupportedData         = 1003
+	CloseNoStatusReceived        = 1005
+	CloseAbnormalClosure         = 1006
+	CloseInvalidFramePayloadData = 1007
+	ClosePolicyViolation         = 1008
+	CloseMessageTooBig           = 1009
+	CloseMandatoryExtension      = 1010
+	CloseInternalServerErr       = 1011
+)
+
+const (
+	websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	finBit  = 1 << 7
+	rsv1Bit = 1 << 6
+	rsv2Bit = 1 << 5
+	rsv3Bit = 1 << 4
+	maskBit = 1 << 7
+
+	maxControlPayload = 125
+
+	// defaultWebSocketReadLimit is the max size of a message read by WebSocket when ReadLimit is not set.
+	defaultWebSocketReadLimit = 32 << 20
+)
+
+// deflateTail is appended to a compressed message before it is inflated, it is the tail
+// removed by the sender followed by an empty final block, see RFC 7692 section 7.2.2.
+const deflateTail = "\x00\x00\xff\xff\x01\x00\x00\xff\xff"
+
+// ErrWebSocketClosed is returned when writing to a WebSocket which has sent the close frame.
+var ErrWebSocketClosed = errors.New("websocket: close sent")
+
+// Upgrader upgrades HTTP connections to WebSocket, the zero value is usable. Engine.Upgrader
+// is used by Context.Upgrade, routes with other settings call Upgrader.Upgrade.
+type Upgrader struct {
+	// Subprotocols supported by the server in order of preference, the first one offered by
+	// the client is selected.
+	Subprotocols []string
+
+	// CheckOrigin returns false to reject the request with 403 Forbidden. By default requests
+	// from the same host, without Origin header, or allowed by the cors middleware are accepted.
+	CheckOrigin func(c *Context) bool
+
+	// EnableCompression negotiates permessage-deflate with the client, messages are
+	// compressed without context takeover.
+	EnableCompression bool
+
+	// ReadLimit is the max size of a message read from the client, default is 32MB. The
+	// connection is closed with CloseMessageTooBig if a message exceeds it.
+	ReadLimit int64
+}
+
+// CloseError is returned by WebSocket.ReadMessage when the connection is closed, either by
+// the client or because the client violated the protocol. Code is the close code sent or
+// received.
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: close %d %s", e.Code, e.Text)
+}
+
+// WebSocket is a connection upgraded by Context.Upgrade. One goroutine can read and another
+// can write concurrently, control frames are answered while reading.
+type WebSocket struct {
+	conn net.Conn
+	br   *bufio.Reader
+	bw   *bufio.Writer
+
+	subprotocol string
+	compression bool
+	readLimit   int64
+	readErr     error
+
+	pingHandler func(data string) error
+	pongHandler func(data string) error
+
+	writeMu   sync.Mutex
+	closeSent bool
+}
+
+// wsFrame is a frame read from the client.
+type wsFrame struct {
+	fin     bool
+	rsv1    bool
+	opcode  int
+	payload []byte
+}
+
+// Upgrade upgrades the connection to WebSocket with Engine.Upgrader. It must be called by
+// the handler before anything is written, middlewares such as authentication and cors run
+// before it as usual. If the handshake fails the error response is written and an error is
+// returned, the handler should return then.
+//
+//	router.Get("/ws", func(c *looli.Context) {
+//		ws, err := c.Upgrade()
+//		if err != nil {
+//			return
+//		}
+//		defer ws.Close(looli.CloseNormalClosure, "")
+//		for {
+//			messageType, data, err := ws.ReadMessage()
+//			if err != nil {
+//				return
+//			}
+//			ws.WriteMessage(messageType, data)
+//		}
+//	})
+func (c *Context) Upgrade() (*WebSocket, error) {
+	return c.engine.Upgrader.Upgrade(c)
+}
+
+// Upgrade upgrades the connection of c to WebSocket, the headers set on c by middlewares are
+// sent with the handshake response.
+func (u *Upgrader) Upgrade(c *Context) (*WebSocket, error) {
+	req := c.Request
+	if c.Written() {
+		return nil, errors.New("websocket: response already written")
+	}
+
+	fail := func(code int, reason string) (*WebSocket, error) {
+		c.String(code, reason)
+		return nil, errors.New("websocket: " + reason)
+	}
+
+	if req.Method != http.MethodGet {
+		return fail(http.StatusMethodNotAllowed, "request method is not GET")
+	}
+	if !headerContainsToken(req.Header, "Connection", "upgrade") {
+		return fail(http.StatusBadRequest, "'upgrade' token not found in 'Connection' header")
+	}
+	if !headerContainsToken(req.Header, "Upgrade", "websocket") {
+		return fail(http.StatusBadRequest, "'websocket' token not found in 'Upgrade' header")
+	}
+	if req.Header.Get("Sec-WebSocket-Version") != "13" {
+		c.SetHeader("Sec-WebSocket-Version", "13")
+		return fail(http.StatusUpgradeRequired, "unsupported version of 'Sec-WebSocket-Version'")
+	}
+
+	key := req.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return fail(http.StatusBadRequest, "invalid 'Sec-WebSocket-Key' header")
+	}
+
+	checkOrigin := u.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = checkSameOrigin
+	}
+	if !checkOrigin(c) {
+		return fail(http.StatusForbidden, "origin is not allowed")
+	}
+
+	subprotocol := u.selectSubprotocol(req)
+	var extension string
+	if u.EnableCompression {
+		extension = negotiateDeflate(req.Header)
+	}
+
+	conn, brw, err := c.writer.Hijack()
+	if err != nil {
+		return fail(http.StatusInternalServerError, err.Error())
+	}
+	c.writer.status = http.StatusSwitchingProtocols
+
+	// clear the deadlines set by http.Server
+	conn.SetDeadline(time.Time{})
+
+	var buf bytes.Buffer
+	buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	buf.WriteString("Sec-WebSocket-Accept: " + computeAcceptKey(key) + "\r\n")
+	if subprotocol != "" {
+		buf.WriteString("Sec-WebSocket-Protocol: " + subprotocol + "\r\n")
+	}
+	if extension != "" {
+		buf.WriteString("Sec-WebSocket-Extensions: " + extension + "\r\n")
+	}
+	for name, values := range c.ResponseWriter.Header() {
+		switch name {
+		case "Upgrade", "Connection", "Sec-Websocket-Accept", "Sec-Websocket-Protocol", "Sec-Websocket-Extensions":
+			continue
+		}
+		if !validHeaderName(name) {
+			continue
+		}
+		for _, value := range values {
+			buf.WriteString(name + ": " + sanitizeHeaderValue(value) + "\r\n")
+		}
+	}
+	buf.WriteString("\r\n")
+
+	if _, err := conn.Write(buf.Bytes()); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	readLimit := u.ReadLimit
+	if readLimit <= 0 {
+		readLimit = defaultWebSocketReadLimit
+	}
+
+	ws := &WebSocket{
+		conn:        conn,
+		br:          brw.Reader,
+		bw:          brw.Writer,
+		subprotocol: subprotocol,
+		compression: extension != "",
+		readLimit:   readLimit,
+	}
+	ws.pingHandler = func(data string) error {
+		err := ws.writeFrame(PongMessage, false, []byte(data))
+		if err == ErrWebSocketClosed {
+			return nil
+		}
+		return err
+	}
+	ws.pongHandler = func(string) error { return nil }
+	return ws, nil
+}
+
+func (u *Upgrader) selectSubprotocol(req *http.Request) string {
+	for _, offered := range headerTokens(req.Header, "Sec-WebSocket-Protocol") {
+		for _, supported := range u.Subprotocols {
+			if offered == supported {
+				return supported
+			}
+		}
+	}
+	return ""
+}
+
+// checkSameOrigin accepts requests without Origin header, from the same host, or with
+// origin allowed by the cors middleware.
+func checkSameOrigin(c *Context) bool {
+	origin := c.Header("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, c.Request.Host) {
+		return true
+	}
+
+	allowed := c.ResponseWriter.Header().Get("Access-Control-Allow-Origin")
+	return allowed == "*" || allowed == origin
+}
+
+// negotiateDeflate returns the accepted permessage-deflate extension, or "" if the client
+// didn't offer one which can be accepted.
+func negotiateDeflate(header http.Header) string {
+	for _, offer := range headerTokens(header, "Sec-WebSocket-Extensions") {
+		params := strings.Split(offer, ";")
+		if strings.TrimSpace(params[0]) != "permessage-deflate" {
+			continue
+		}
+
+		response := "permessage-deflate; server_no_context_takeover; client_no_context_takeover"
+		accept := true
+		for _, param := range params[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			value = strings.Trim(strings.TrimSpace(value), `"`)
+			switch strings.TrimSpace(name) {
+			case "server_no_context_takeover", "client_no_context_takeover", "client_max_window_bits":
+			case "server_max_window_bits":
+				// the window of compress/flate can not be reduced
+				if value != "15" {
+					accept = false
+				}
+				response += "; server_max_window_bits=15"
+			default:
+				accept = false
+			}
+		}
+		if accept {
+			return response
+		}
+	}
+	return ""
+}
+
+// headerContainsToken reports whether the comma separated values of header name contain token.
+func headerContainsToken(header http.Header, name, token string) bool {
+	for _, value := range headerTokens(header, name) {
+		if strings.EqualFold(value, token) {
+			return true
+		}
+	}
+	return false
+}
+
+func headerTokens(header http.Header, name string) []string {
+	var tokens []string
+	for _, value := range header.Values(name) {
+		for _, token := range strings.Split(value, ",") {
+			if token = strings.TrimSpace(token); token != "" {
+				tokens = append(tokens, token)
+			}
+		}
+	}
+	return tokens
+}
+
+// validHeaderName reports whether name is a token (RFC 9110), headers with other names are
+// not written to the handshake response.
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		b := name[i]
+		if !('a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9' || strings.IndexByte("!#$%&'*+-.^_`|~", b) >= 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// sanitizeHeaderValue removes the control characters except horizontal tab from value, so
+// that it can not end the header early or inject other headers into the handshake response.
+func sanitizeHeaderValue(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r < ' ' && r != '\t' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, value)
+}
+
+func computeAcceptKey(key string) string {
+	h := sha1.New()
+	io.WriteString(h, key+websocketGUID)
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// Subprotocol returns the subprotocol selected in the handshake.
+func (ws *WebSocket) Subprotocol() string {
+	return ws.subprotocol
+}
+
+// RemoteAddr returns the remote network address.
+func (ws *WebSocket) RemoteAddr() net.Addr {
+	return ws.conn.RemoteAddr()
+}
+
+// SetReadDeadline sets the deadline of reading, zero value means no deadline.
+func (ws *WebSocket) SetReadDeadline(t time.Time) error {
+	return ws.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the deadline of writing, zero value means no deadline.
+func (ws *WebSocket) SetWriteDeadline(t time.Time) error {
+	return ws.conn.SetWriteDeadline(t)
+}
+
+// SetPingHandler sets the handler called with the payload of ping frames, the default
+// handler replies with a pong frame.
+func (ws *WebSocket) SetPingHandler(handler func(data string) error) {
+	ws.pingHandler = handler
+}
+
+// SetPongHandler sets the handler called with the payload of pong frames.
+func (ws *WebSocket) SetPongHandler(handler func(data string) error) {
+	ws.pongHandler = handler
+}
+
+// ReadMessage reads the next data message, fragmented and compressed messages are
+// assembled, control frames are handled on the way. A *CloseError is returned when the
+// connection is closed by the client or because of a protocol violation, after an error
+// all calls return the same error.
+func (ws *WebSocket) ReadMessage() (messageType int, data []byte, err error) {
+	if ws.readErr != nil {
+		return 0, nil, ws.readErr
+	}
+
+	var (
+		compressed bool
+		message    []byte
+	)
+	for {
+		frame, err := ws.readFrame(int64(len(message)))
+		if err != nil {
+			return 0, nil, ws.failRead(err)
+		}
+
+		switch frame.opcode {
+		case CloseMessage, PingMessage, PongMessage:
+			if err := ws.handleControl(frame); err != nil {
+				return 0, nil, ws.failRead(err)
+			}
+			continue
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				return 0, nil, ws.fail(CloseProtocolError, "data frame in the middle of a fragmented message")
+			}
+			if frame.rsv1 && !ws.compression {
+				return 0, nil, ws.fail(CloseProtocolError, "unexpected rsv1 bit")
+			}
+			messageType, compressed = frame.opcode, frame.rsv1
+		case continuationFrame:
+			if messageType == 0 {
+				return 0, nil, ws.fail(CloseProtocolError, "continuation frame without a message")
+			}
+			if frame.rsv1 {
+				return 0, nil, ws.fail(CloseProtocolError, "unexpected rsv1 bit")
+			}
+		}
+
+		message = append(message, frame.payload...)
+		if frame.fin {
+			break
+		}
+	}
+
+	if compressed {
+		if message, err = inflateMessage(message, ws.readLimit); err != nil {
+			if err == errMessageTooBig {
+				return 0, nil, ws.fail(CloseMessageTooBig, "message too big")
+			}
+			return 0, nil, ws.fail(CloseProtocolError, "invalid compressed message")
+		}
+	}
+	if messageType == TextMessage && !utf8.Valid(message) {
+		return 0, nil, ws.fail(CloseInvalidFramePayloadData, "invalid utf-8 in text message")
+	}
+	return messageType, message, nil
+}
+
+// ReadJSON reads the next message and decodes it as JSON into v.
+func (ws *WebSocket) ReadJSON(v interface{}) error {
+	_, data, err := ws.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+var errMessageTooBig = errors.New("websocket: message too big")
+
+// readFrame reads a frame, pending is the size of the fragments of the message read before.
+func (ws *WebSocket) readFrame(pending int64) (*wsFrame, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(ws.br, header[:]); err != nil {
+		return nil, err
+	}
+
+	frame := &wsFrame{
+		fin:    header[0]&finBit != 0,
+		rsv1:   header[0]&rsv1Bit != 0,
+		opcode: int(header[0] & 0xf),
+	}
+	if header[0]&(rsv2Bit|rsv3Bit) != 0 {
+		return nil, &CloseError{Code: CloseProtocolError, Text: "unexpected rsv2 or rsv3 bit"}
+	}
+	if header[1]&maskBit == 0 {
+		return nil, &CloseError{Code: CloseProtocolError, Text: "frame from client is not masked"}
+	}
+
+	length := int64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var extended [2]byte
+		if _, err := io.ReadFull(ws.br, extended[:]); err != nil {
+			return nil, err
+		}
+		length = int64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		if _, err := io.ReadFull(ws.br, extended[:]); err != nil {
+			return nil, err
+		}
+		length = int64(binary.BigEndian.Uint64(extended[:]))
+		if length < 0 {
+			return nil, &CloseError{Code: CloseProtocolError, Text: "invalid payload length"}
+		}
+	}
+
+	switch frame.opcode {
+	case CloseMessage, PingMessage, PongMessage:
+		if !frame.fin || length > maxControlPayload || frame.rsv1 {
+			return nil, &CloseError{Code: CloseProtocolError, Text: "invalid control frame"}
+		}
+	case TextMessage, BinaryMessage, continuationFrame:
+		if length > ws.readLimit-pending {
+			return nil, &CloseError{Code: CloseMessageTooBig, Text: "message too big"}
+		}
+	default:
+		return nil, &CloseError{Code: CloseProtocolError, Text: fmt.Sprintf("unknown opcode %d", frame.opcode)}
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(ws.br, mask[:]); err != nil {
+		return nil, err
+	}
+
+	frame.payload = make([]byte, length)
+	if _, err := io.ReadFull(ws.br, frame.payload); err != nil {
+		return nil, err
+	}
+	for i := range frame.payload {
+		frame.payload[i] ^= mask[i%4]
+	}
+	return frame, nil
+}
+
+func (ws *WebSocket) handleControl(frame *wsFrame) error {
+	switch frame.opcode {
+	case PingMessage:
+		return ws.pingHandler(string(frame.payload))
+	case PongMessage:
+		return ws.pongHandler(string(frame.payload))
+	}
+
+	closeErr := &CloseError{Code: CloseNoStatusReceived}
+	switch {
+	case len(frame.payload) == 1:
+		return ws.fail(CloseProtocolError, "invalid close payload")
+	case len(frame.payload) >= 2:
+		closeErr.Code = int(binary.BigEndian.Uint16(frame.payload))
+		closeErr.Text = string(frame.payload[2:])
+		if !validCloseCode(closeErr.Code) {
+			return ws.fail(CloseProtocolError, "invalid close code")
+		}
+		if !utf8.ValidString(closeErr.Text) {
+			return ws.fail(CloseInvalidFramePayloadData, "invalid utf-8 in close reason")
+		}
+	}
+
+	code := closeErr.Code
+	if code == CloseNoStatusReceived {
+		code = CloseNormalClosure
+	}
+	ws.writeFrame(CloseMessage, false, closePayload(code, ""))
+	ws.conn.Close()
+	return closeErr
+}
+
+// validCloseCode reports whether code can be sent in a close frame.
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1014:
+		return true
+	case code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}
+
+// fail closes the connection with code and returns the *CloseError.
+func (ws *WebSocket) fail(code int, text string) error {
+	ws.writeFrame(CloseMessage, false, closePayload(code, text))
+	ws.conn.Close()
+	ws.readErr = &CloseError{Code: code, Text: text}
+	return ws.readErr
+}
+
+// failRead records err as the error of following reads and closes the connection, with
+// the code of err if it is *CloseError.
+func (ws *WebSocket) failRead(err error) error {
+	if ws.readErr != nil {
+		return ws.readErr
+	}
+	if closeErr, ok := err.(*CloseError); ok {
+		return ws.fail(closeErr.Code, closeErr.Text)
+	}
+
+	ws.conn.Close()
+	ws.readErr = err
+	return err
+}
+
+func closePayload(code int, text string) []byte {
+	payload := make([]byte, 2, 2+len(text))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, text...)
+	if len(payload) > maxControlPayload {
+		payload = payload[:maxControlPayload]
+	}
+	return payload
+}
+
+// WriteMessage writes a message of messageType, data messages are compressed if
+// permessage-deflate is negotiated.
+func (ws *WebSocket) WriteMessage(messageType int, data []byte) error {
+	switch messageType {
+	case TextMessage, BinaryMessage:
+		if ws.compression {
+			return ws.writeFrame(messageType, true, deflateMessage(data))
+		}
+	case CloseMessage, PingMessage, PongMessage:
+		if len(data) > maxControlPayload {
+			return errors.New("websocket: control frame payload is too large")
+		}
+	default:
+		return fmt.Errorf("websocket: unknown message type %d", messageType)
+	}
+	return ws.writeFrame(messageType, false, data)
+}
+
+// WriteJSON writes v as a JSON text message.
+func (ws *WebSocket) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return ws.WriteMessage(TextMessage, data)
+}
+
+// Ping sends a ping frame, the pong is handled by the pong handler while reading.
+func (ws *WebSocket) Ping(data []byte) error {
+	return ws.WriteMessage(PingMessage, data)
+}
+
+// Close sends a close frame with code and reason, then closes the connection.
+func (ws *WebSocket) Close(code int, reason string) error {
+	err := ws.writeFrame(CloseMessage, false, closePayload(code, reason))
+	if closeErr := ws.conn.Close(); err == nil || err == ErrWebSocketClosed {
+		err = closeErr
+	}
+	return err
+}
+
+func (ws *WebSocket) writeFrame(opcode int, compressed bool, payload []byte) error {
+	ws.writeMu.Lock()
+	defer ws.writeMu.Unlock()
+
+	if ws.closeSent {
+		return ErrWebSocketClosed
+	}
+	if opcode == CloseMessage {
+		ws.closeSent = true
+	}
+
+	header := make([]byte, 0, 10)
+	b0 := byte(opcode) | finBit
+	if compressed {
+		b0 |= rsv1Bit
+	}
+	header = append(header, b0)
+
+	length := len(payload)
+	switch {
+	case length <= 125:
+		header = append(header, byte(length))
+	case length <= 0xffff:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(length))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(length))
+	}
+
+	if _, err := ws.bw.Write(header); err != nil {
+		return err
+	}
+	if _, err := ws.bw.Write(payload); err != nil {
+		return err
+	}
+	return ws.bw.Flush()
+}
+
+// deflateMessage compresses data without context takeover, the tail of the sync flush is
+// removed, see RFC 7692 section 7.2.1.
+func deflateMessage(data []byte) []byte {
+	var buf bytes.Buffer
+	fw, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+	fw.Write(data)
+	fw.Flush()
+	return bytes.TrimSuffix(buf.Bytes(), []byte(deflateTail[:4]))
+}
+
+// inflateMessage decompresses data, errMessageTooBig is returned if the result exceeds limit.
+func inflateMessage(data []byte, limit int64) ([]byte, error) {
+	fr := flate.NewReader(io.MultiReader(bytes.NewReader(data), strings.NewReader(deflateTail)))
+	defer fr.Close()
+
+	message, err := io.ReadAll(io.LimitReader(fr, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(message)) > limit {
+		return nil, errMessageTooBig
+	}
+	return message, nil
+}
@@ -0,0 +1,335 @@
+package looli
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testWebSocketKey = "dGhlIHNhbXBsZSBub25jZQ=="
+
+// wsClient is a minimal WebSocket client used to test the server.
+type wsClient struct {
+	conn net.Conn
+	br   *bufio.Reader
+	resp *http.Response
+}
+
+func dialWebSocket(t *testing.T, serverURL, path string, header http.Header) *wsClient {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(serverURL, "http://"))
+	assert.Nil(t, err)
+
+	req, _ := http.NewRequest(http.MethodGet, serverURL+path, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", testWebSocketKey)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	assert.Nil(t, req.Write(conn))
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	assert.Nil(t, err)
+	return &wsClient{conn: conn, br: br, resp: resp}
+}
+
+func (client *wsClient) writeFrame(b0 byte, payload []byte) {
+	frame := []byte{b0}
+	switch {
+	case len(payload) <= 125:
+		frame = append(frame, maskBit|byte(len(payload)))
+	case len(payload) <= 0xffff:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(payload)))
+	}
+
+	mask := []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	client.conn.Write(frame)
+}
+
+func (client *wsClient) readFrame(t *testing.T) (b0 byte, payload []byte) {
+	var header [2]byte
+	_, err := io.ReadFull(client.br, header[:])
+	assert.Nil(t, err)
+
+	length := int(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var extended [2]byte
+		io.ReadFull(client.br, extended[:])
+		length = int(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		io.ReadFull(client.br, extended[:])
+		length = int(binary.BigEndian.Uint64(extended[:]))
+	}
+
+	payload = make([]byte, length)
+	_, err = io.ReadFull(client.br, payload)
+	assert.Nil(t, err)
+	return header[0], payload
+}
+
+func (client *wsClient) expectClose(t *testing.T, code int) {
+	b0, payload := client.readFrame(t)
+	assert.Equal(t, byte(finBit|CloseMessage), b0)
+	assert.True(t, len(payload) >= 2)
+	assert.Equal(t, code, int(binary.BigEndian.Uint16(payload)))
+}
+
+func newEchoServer(upgrader *Upgrader, errs chan error) *httptest.Server {
+	router := New()
+	router.Get("/ws", func(c *Context) {
+		ws, err := upgrader.Upgrade(c)
+		if err != nil {
+			return
+		}
+		defer ws.Close(CloseNormalClosure, "")
+
+		for {
+			messageType, data, err := ws.ReadMessage()
+			if err != nil {
+				if _, ok := err.(*CloseError); ok && errs != nil {
+					errs <- err
+				}
+				return
+			}
+			ws.WriteMessage(messageType, data)
+		}
+	})
+	return httptest.NewServer(router)
+}
+
+func TestWebSocketHandshake(t *testing.T) {
+	router := New()
+	router.Use(func(c *Context) {
+		if c.Query("token") != "secret" {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.SetHeader("X-Middleware", "true")
+		c.Next()
+	})
+	router.Get("/ws", func(c *Context) {
+		upgrader := &Upgrader{Subprotocols: []string{"chat", "superchat"}}
+		ws, err := upgrader.Upgrade(c)
+		if err != nil {
+			return
+		}
+		assert.Equal(t, "chat", ws.Subprotocol())
+		ws.Close(CloseNormalClosure, "")
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	t.Run("accept", func(t *testing.T) {
+		client := dialWebSocket(t, server.URL, "/ws?token=secret", http.Header{
+			"Sec-Websocket-Protocol": {"unknown, chat"},
+		})
+		defer client.conn.Close()
+
+		assert.Equal(t, http.StatusSwitchingProtocols, client.resp.StatusCode)
+		assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", client.resp.Header.Get("Sec-WebSocket-Accept"))
+		assert.Equal(t, "chat", client.resp.Header.Get("Sec-WebSocket-Protocol"))
+		assert.Equal(t, "true", client.resp.Header.Get("X-Middleware"))
+		client.expectClose(t, CloseNormalClosure)
+	})
+
+	t.Run("middleware abort", func(t *testing.T) {
+		client := dialWebSocket(t, server.URL, "/ws", nil)
+		defer client.conn.Close()
+		assert.Equal(t, http.StatusUnauthorized, client.resp.StatusCode)
+	})
+
+	t.Run("unsupported version", func(t *testing.T) {
+		client := dialWebSocket(t, server.URL, "/ws?token=secret", http.Header{
+			"Sec-Websocket-Version": {"8"},
+		})
+		defer client.conn.Close()
+		assert.Equal(t, http.StatusUpgradeRequired, client.resp.StatusCode)
+		assert.Equal(t, "13", client.resp.Header.Get("Sec-WebSocket-Version"))
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		client := dialWebSocket(t, server.URL, "/ws?token=secret", http.Header{
+			"Sec-Websocket-Key": {"invalid"},
+		})
+		defer client.conn.Close()
+		assert.Equal(t, http.StatusBadRequest, client.resp.StatusCode)
+	})
+
+	t.Run("cross origin", func(t *testing.T) {
+		client := dialWebSocket(t, server.URL, "/ws?token=secret", http.Header{
+			"Origin": {"http://evil.com"},
+		})
+		defer client.conn.Close()
+		assert.Equal(t, http.StatusForbidden, client.resp.StatusCode)
+	})
+
+	t.Run("not upgrade request", func(t *testing.T) {
+		resp, err := http.Get(server.URL + "/ws?token=secret")
+		assert.Nil(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestWebSocketHandshakeHeaders(t *testing.T) {
+	router := New()
+	router.Get("/ws", func(c *Context) {
+		header := c.ResponseWriter.Header()
+		header["X-Injected"] = []string{"a\r\nSet-Cookie: session=evil\r\n\r\nbody"}
+		header["X-Control"] = []string{"a\x00b\x7fc\td"}
+		header["X-Bad\r\nName"] = []string{"value"}
+		ws, err := c.Upgrade()
+		if err != nil {
+			return
+		}
+		ws.Close(CloseNormalClosure, "")
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	client := dialWebSocket(t, server.URL, "/ws", nil)
+	defer client.conn.Close()
+
+	assert.Equal(t, http.StatusSwitchingProtocols, client.resp.StatusCode)
+	assert.Equal(t, "aSet-Cookie: session=evilbody", client.resp.Header.Get("X-Injected"))
+	assert.Equal(t, "abc\td", client.resp.Header.Get("X-Control"))
+	assert.Empty(t, client.resp.Header.Values("Set-Cookie"))
+	assert.Empty(t, client.resp.Header.Values("Name"))
+	client.expectClose(t, CloseNormalClosure)
+}
+
+func TestWebSocketMessages(t *testing.T) {
+	errs := make(chan error, 1)
+	server := newEchoServer(&Upgrader{ReadLimit: 1024}, errs)
+	defer server.Close()
+
+	t.Run("echo", func(t *testing.T) {
+		client := dialWebSocket(t, server.URL, "/ws", nil)
+		defer client.conn.Close()
+
+		client.writeFrame(finBit|TextMessage, []byte("hello"))
+		b0, payload := client.readFrame(t)
+		assert.Equal(t, byte(finBit|TextMessage), b0)
+		assert.Equal(t, "hello", string(payload))
+
+		large := []byte(strings.Repeat("a", 1000))
+		client.writeFrame(finBit|BinaryMessage, large)
+		b0, payload = client.readFrame(t)
+		assert.Equal(t, byte(finBit|BinaryMessage), b0)
+		assert.Equal(t, large, payload)
+	})
+
+	t.Run("fragmented with ping", func(t *testing.T) {
+		client := dialWebSocket(t, server.URL, "/ws", nil)
+		defer client.conn.Close()
+
+		client.writeFrame(TextMessage, []byte("hel"))
+		client.writeFrame(finBit|PingMessage, []byte("ping"))
+		client.writeFrame(continuationFrame, []byte("lo "))
+		client.writeFrame(finBit|continuationFrame, []byte("world"))
+
+		b0, payload := client.readFrame(t)
+		assert.Equal(t, byte(finBit|PongMessage), b0)
+		assert.Equal(t, "ping", string(payload))
+
+		b0, payload = client.readFrame(t)
+		assert.Equal(t, byte(finBit|TextMessage), b0)
+		assert.Equal(t, "hello world", string(payload))
+	})
+
+	t.Run("close by client", func(t *testing.T) {
+		client := dialWebSocket(t, server.URL, "/ws", nil)
+		defer client.conn.Close()
+
+		client.writeFrame(finBit|CloseMessage, closePayload(CloseGoingAway, "bye"))
+		client.expectClose(t, CloseGoingAway)
+		assert.Equal(t, &CloseError{Code: CloseGoingAway, Text: "bye"}, <-errs)
+	})
+
+	cases := []struct {
+		name   string
+		frames func(client *wsClient)
+		code   int
+	}{
+		{"unmasked frame", func(client *wsClient) {
+			client.conn.Write([]byte{finBit | TextMessage, 0})
+		}, CloseProtocolError},
+		{"invalid utf-8", func(client *wsClient) {
+			client.writeFrame(finBit|TextMessage, []byte{0xff, 0xfe})
+		}, CloseInvalidFramePayloadData},
+		{"too big", func(client *wsClient) {
+			client.writeFrame(BinaryMessage, make([]byte, 1000))
+			client.writeFrame(finBit|continuationFrame, make([]byte, 100))
+		}, CloseMessageTooBig},
+		{"unexpected continuation", func(client *wsClient) {
+			client.writeFrame(finBit|continuationFrame, []byte("a"))
+		}, CloseProtocolError},
+		{"fragmented control frame", func(client *wsClient) {
+			client.writeFrame(PingMessage, []byte("a"))
+		}, CloseProtocolError},
+		{"reserved bit", func(client *wsClient) {
+			client.writeFrame(finBit|rsv1Bit|TextMessage, []byte("a"))
+		}, CloseProtocolError},
+		{"invalid close code", func(client *wsClient) {
+			client.writeFrame(finBit|CloseMessage, closePayload(1004, ""))
+		}, CloseProtocolError},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := dialWebSocket(t, server.URL, "/ws", nil)
+			defer client.conn.Close()
+
+			tc.frames(client)
+			client.expectClose(t, tc.code)
+			err := <-errs
+			assert.IsType(t, &CloseError{}, err)
+			assert.Equal(t, tc.code, err.(*CloseError).Code)
+		})
+	}
+}
+
+func TestWebSocketCompression(t *testing.T) {
+	server := newEchoServer(&Upgrader{EnableCompression: true}, nil)
+	defer server.Close()
+
+	client := dialWebSocket(t, server.URL, "/ws", http.Header{
+		"Sec-Websocket-Extensions": {"permessage-deflate; server_max_window_bits=10, permessage-deflate; client_max_window_bits"},
+	})
+	defer client.conn.Close()
+	assert.Equal(t, "permessage-deflate; server_no_context_takeover; client_no_context_takeover",
+		client.resp.Header.Get("Sec-WebSocket-Extensions"))
+
+	message := strings.Repeat("compressed message ", 100)
+	client.writeFrame(finBit|rsv1Bit|TextMessage, deflateMessage([]byte(message)))
+
+	b0, payload := client.readFrame(t)
+	assert.Equal(t, byte(finBit|rsv1Bit|TextMessage), b0)
+	assert.True(t, len(payload) < len(message))
+
+	inflated, err := inflateMessage(payload, int64(len(message)))
+	assert.Nil(t, err)
+	assert.Equal(t, message, string(inflated))
+
+	_, err = inflateMessage(payload, 10)
+	assert.Equal(t, errMessageTooBig, err)
+}